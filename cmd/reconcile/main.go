@@ -0,0 +1,58 @@
+// Command reconcile compares every item's stock with the sum of its inventory
+// ledger and optionally posts correcting "audit" entries.
+//
+//	go run ./cmd/reconcile            # report only
+//	go run ./cmd/reconcile -fix       # report and post audit entries
+//	go run ./cmd/reconcile -item 42   # single item
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/services"
+)
+
+func main() {
+	fix := flag.Bool("fix", false, "post audit entries for every discrepancy")
+	itemID := flag.Uint("item", 0, "only reconcile this item ID")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	config.ConnectDatabase()
+
+	input := dtos.ReconcileInventoryInput{Fix: *fix}
+	if *itemID > 0 {
+		id := *itemID
+		input.ItemID = &id
+	}
+
+	service := services.NewInventoryService()
+	result, err := service.ReconcileInventory(input, nil)
+	if err != nil {
+		log.Fatal("Reconciliation failed: ", err)
+	}
+
+	fmt.Printf("Checked %d item(s), %d discrepancy(ies)\n", result.CheckedItems, len(result.Discrepancies))
+	for _, d := range result.Discrepancies {
+		status := ""
+		if d.Fixed {
+			status = " [fixed]"
+		}
+		fmt.Printf("  #%-6d %-40s stock=%-6d ledger=%-6d diff=%+d%s\n",
+			d.ItemID, d.Name, d.Stock, d.LedgerStock, d.Difference, status)
+	}
+
+	if len(result.Discrepancies) > 0 && !*fix {
+		os.Exit(1)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"kd-api/models"
+	logutil "kd-api/utils/log"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -33,15 +34,69 @@ func ConnectDatabase() {
 		&models.Transaction{},
 		&models.TransactionItem{},
 		&models.User{},
+		&models.Role{},
+		&models.RolePermission{},
+		&models.PasswordHistory{},
+		&models.Session{},
+		&models.Terminal{},
+		&models.RecoveryCode{},
+		&models.APIKey{},
+		&models.APIKeyPermission{},
+		&models.LoginAttempt{},
 		&models.Attendance{},
 		&models.AuditLog{},
 		&models.CashSession{},
 		&models.InventoryLog{},
+		&models.Location{},
+		&models.ItemStock{},
+		&models.StockTransfer{},
+		&models.StockTransferItem{},
+		&models.StockTransferItemLot{},
+		&models.StockReservation{},
+		&models.GoodsReceipt{},
+		&models.GoodsReceiptItem{},
+		&models.CostLayer{},
+		&models.Lot{},
+		&models.TransactionItemLot{},
+		&models.Supplier{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderItem{},
+		&models.SerialNumber{},
+		&models.KitComponent{},
+		&models.TransactionItemComponent{},
+		&models.PriceHistory{},
+		&models.ScheduledPriceChange{},
+		&models.DataMigration{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database: ", err)
 	}
 
+	if err := seedRoles(db); err != nil {
+		log.Fatal("Failed to seed roles: ", err)
+	}
+
+	if err := seedDefaultLocation(db); err != nil {
+		log.Fatal("Failed to seed default location: ", err)
+	}
+
+	if err := runOnce(db, "backfill_costs", backfillCosts); err != nil {
+		log.Fatal("Failed to backfill item costs: ", err)
+	}
+
+	if err := runOnce(db, "backfill_lot_locations", backfillLotLocations); err != nil {
+		log.Fatal("Failed to backfill lot locations: ", err)
+	}
+
+	if err := runOnce(db, "backfill_price_history", backfillPriceHistory); err != nil {
+		log.Fatal("Failed to backfill price history: ", err)
+	}
+
+	// Registered after seeding so startup data does not fill the audit log
+	if err := logutil.RegisterAuditHooks(db); err != nil {
+		log.Fatal("Failed to register audit hooks: ", err)
+	}
+
 	DB = db
 	fmt.Println("✅ Database connected & migrated successfully")
 }
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"kd-api/models"
+	"kd-api/utils/permission"
+
+	"gorm.io/gorm"
+)
+
+// What the cashier role may do when it is first created: ring up sales, finish or
+// throw away drafts, and add or edit items. Stock adjustments, item deletes and
+// refunds are left to roles an admin grants them to.
+var defaultCashierPermissions = []string{
+	permission.ItemsCreate,
+	permission.ItemsUpdate,
+	permission.ItemsExport,
+	permission.InventoryTransfer,
+	permission.TransactionsCreate,
+	permission.TransactionsUpdateStatus, // only drafts can be completed
+	permission.TransactionsDelete,       // only drafts can be deleted
+	permission.ReportsView,
+}
+
+// seedRoles creates the admin and cashier roles when missing. Admin is given any
+// permission it lacks on every start, so new permissions reach it without a migration.
+func seedRoles(db *gorm.DB) error {
+	system := []struct {
+		name        string
+		description string
+		permissions []string
+	}{
+		{"admin", "Full access", permission.All()},
+		{"cashier", "Point of sale", defaultCashierPermissions},
+	}
+
+	for _, seed := range system {
+		var role models.Role
+		err := db.Where("name = ?", seed.name).First(&role).Error
+		if err == gorm.ErrRecordNotFound {
+			role = models.Role{Name: seed.name, Description: seed.description, IsSystem: true}
+			if err := db.Create(&role).Error; err != nil {
+				return err
+			}
+			for _, name := range seed.permissions {
+				if err := db.Create(&models.RolePermission{RoleID: role.ID, Permission: name}).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if seed.name != "admin" {
+			continue
+		}
+		var granted []string
+		if err := db.Model(&models.RolePermission{}).Where("role_id = ?", role.ID).
+			Pluck("permission", &granted).Error; err != nil {
+			return err
+		}
+		has := make(map[string]bool, len(granted))
+		for _, name := range granted {
+			has[name] = true
+		}
+		for _, name := range seed.permissions {
+			if !has[name] {
+				if err := db.Create(&models.RolePermission{RoleID: role.ID, Permission: name}).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// seedDefaultLocation makes sure there is a default location and that every
+// item has its stock assigned to at least one location.
+func seedDefaultLocation(db *gorm.DB) error {
+	var location models.Location
+	err := db.Where("is_default = ?", true).First(&location).Error
+	if err == gorm.ErrRecordNotFound {
+		location = models.Location{Name: "Toko", Code: "TOKO", IsDefault: true}
+		err = db.Create(&location).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	// Items created before locations existed keep their stock at the default location
+	return db.Exec(
+		"INSERT INTO item_stocks (item_id, location_id, quantity, updated_at) "+
+			"SELECT id, ?, stock, NOW() FROM items "+
+			"WHERE deleted_at IS NULL AND id NOT IN (SELECT item_id FROM item_stocks)",
+		location.ID,
+	).Error
+}
+
+// runOnce applies a one-time data fix and records it, in one transaction, so
+// later starts skip it.
+func runOnce(db *gorm.DB, name string, fix func(tx *gorm.DB) error) error {
+	var count int64
+	if err := db.Model(&models.DataMigration{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := fix(tx); err != nil {
+			return err
+		}
+		return tx.Create(&models.DataMigration{Name: name}).Error
+	})
+}
+
+// backfillCosts gives data from before cost tracking a starting point: items cost
+// their BuyPrice, their current stock becomes one opening cost layer, and completed
+// sales without a cost are costed at the item's BuyPrice. It runs once, later zero
+// costs are real.
+func backfillCosts(db *gorm.DB) error {
+	if err := db.Exec(
+		"UPDATE items SET average_cost = buy_price WHERE average_cost = 0",
+	).Error; err != nil {
+		return err
+	}
+
+	if err := db.Exec(
+		"INSERT INTO cost_layers (item_id, quantity, remaining, unit_cost, reference_id, created_at) " +
+			"SELECT id, stock, stock, average_cost, 'OPENING', NOW() FROM items " +
+			"WHERE stock > 0 AND id NOT IN (SELECT item_id FROM cost_layers)",
+	).Error; err != nil {
+		return err
+	}
+
+	// Drafts get their cost when completed
+	return db.Exec(
+		"UPDATE transaction_items ti " +
+			"JOIN items i ON i.id = ti.item_id " +
+			"JOIN transactions t ON t.id = ti.transaction_id " +
+			"SET ti.unit_cost = i.buy_price WHERE ti.unit_cost = 0 AND t.status = 'completed'",
+	).Error
+}
+
+// backfillLotLocations puts lots from before per-location lots at the location of
+// their goods receipt, or the default location when they have none.
+func backfillLotLocations(db *gorm.DB) error {
+	if err := db.Exec(
+		"UPDATE lots l JOIN goods_receipts gr ON gr.id = l.goods_receipt_id " +
+			"SET l.location_id = gr.location_id WHERE l.location_id = 0",
+	).Error; err != nil {
+		return err
+	}
+
+	return db.Exec(
+		"UPDATE lots SET location_id = (SELECT id FROM locations WHERE is_default = true AND deleted_at IS NULL LIMIT 1) " +
+			"WHERE location_id = 0",
+	).Error
+}
+
+// backfillPriceHistory starts the history of items from before price tracking
+// with their current prices.
+func backfillPriceHistory(db *gorm.DB) error {
+	return db.Exec(
+		"INSERT INTO price_histories (item_id, old_price, new_price, old_buy_price, new_buy_price, source, created_at) " +
+			"SELECT id, price, price, buy_price, buy_price, 'initial', created_at FROM items " +
+			"WHERE id NOT IN (SELECT item_id FROM price_histories)",
+	).Error
+}
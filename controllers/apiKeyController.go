@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetAPIKeys(c *gin.Context) {
+	service := services.NewAPIKeyService()
+	keys, err := service.GetAPIKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// Buat API key untuk integrasi, key hanya ditampilkan sekali
+func CreateAPIKey(c *gin.Context) {
+	var input dtos.CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewAPIKeyService()
+	created, err := service.CreateAPIKey(input, common.GetUserRole(c), common.GetActor(c))
+	if err != nil {
+		switch {
+		case strings.HasPrefix(err.Error(), "unknown permission"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "you cannot grant permission"):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// Cabut API key, langsung tidak berlaku
+func RevokeAPIKey(c *gin.Context) {
+	service := services.NewAPIKeyService()
+	if err := service.RevokeAPIKey(c.Param("id"), common.GetActor(c)); err != nil {
+		switch err.Error() {
+		case "API key not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "API key is already revoked":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"kd-api/dtos"
 	"kd-api/services"
+	"kd-api/utils/common"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -18,7 +19,7 @@ func CreateAttendance(c *gin.Context) {
     }
 
     service := services.NewAttendanceService()
-    attendance, err := service.CreateAttendance(input)
+    attendance, err := service.CreateAttendance(input, common.GetActor(c))
 
     if err != nil {
         if err.Error() == "Cashier sudah diabsen hari ini" {
@@ -36,6 +36,9 @@ func GetAuditLogs(c *gin.Context) {
 	if action := c.Query("action"); action != "" {
 		query = query.Where("action = ?", action)
 	}
+	if apiKeyID := c.Query("api_key_id"); apiKeyID != "" {
+		query = query.Where("api_key_id = ?", apiKeyID)
+	}
 
 	query.Count(&total)
 
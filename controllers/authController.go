@@ -3,7 +3,9 @@ package controllers
 import (
 	"kd-api/dtos"
 	"kd-api/services"
+	"kd-api/utils/common"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,12 +19,246 @@ func Login(c *gin.Context) {
 	}
 
 	service := services.NewAuthService()
-	response, err := service.Login(input)
+	response, err := service.Login(input, c.ClientIP(), c.Request.UserAgent())
 
 	if err != nil {
+		if err.Error() == "Account is disabled" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "Too many failed login attempts") {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
+
+// Ganti password sendiri, wajib menyertakan password lama
+func ChangeOwnPassword(c *gin.Context) {
+	var input dtos.ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := common.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	service := services.NewAuthService()
+	response, err := service.ChangePassword(*userID, input, common.GetActor(c), c.Request.UserAgent())
+	if err != nil {
+		switch {
+		case err.Error() == "Current password is incorrect":
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case err.Error() == "User not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "password "):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Tukar refresh token dengan access token baru (refresh token lama tidak berlaku lagi)
+func RefreshToken(c *gin.Context) {
+	var input dtos.RefreshTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewAuthService()
+	response, err := service.Refresh(input, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout dari session yang sedang dipakai
+func Logout(c *gin.Context) {
+	userID := common.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	service := services.NewAuthService()
+	if err := service.Logout(*userID, common.GetSessionID(c), common.GetActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// Logout dari semua perangkat
+func LogoutAll(c *gin.Context) {
+	userID := common.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	service := services.NewAuthService()
+	if err := service.LogoutAll(*userID, common.GetActor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
+
+// Riwayat percobaan login (admin), filter: username, ip, success, start_date, end_date
+func GetLoginAttempts(c *gin.Context) {
+	var filter dtos.LoginAttemptFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewLoginAttemptService()
+	attempts, err := service.GetLoginAttempts(filter)
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "Invalid date format") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, attempts)
+}
+
+// Login cepat dengan PIN di terminal kasir yang terdaftar (header X-Terminal-Token)
+func PinLogin(c *gin.Context) {
+	var input dtos.PinLoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewAuthService()
+	response, err := service.PinLogin(input, c.GetHeader("X-Terminal-Token"), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch {
+		case err.Error() == "Account is disabled":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "Too many failed login attempts"):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Buka kembali session terminal yang terkunci karena tidak dipakai
+func UnlockSession(c *gin.Context) {
+	var input dtos.UnlockSessionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := common.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	service := services.NewAuthService()
+	err := service.UnlockSession(*userID, common.GetSessionID(c), input, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch {
+		case err.Error() == "Invalid PIN":
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "Too many failed login attempts"):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case err.Error() == "Session not found", err.Error() == "User not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case err.Error() == "only terminal sessions can be unlocked with a PIN":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session unlocked"})
+}
+
+// Atur PIN sendiri untuk login di terminal, dikonfirmasi dengan password
+func SetOwnPin(c *gin.Context) {
+	var input dtos.SetPinInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := common.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	service := services.NewAuthService()
+	if err := service.SetPin(*userID, input, common.GetActor(c)); err != nil {
+		switch {
+		case err.Error() == "Current password is incorrect":
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case err.Error() == "User not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "PIN "):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "PIN set successfully"})
+}
+
+// Selesaikan login dengan kode 2FA (authenticator atau recovery code)
+func VerifyTwoFactor(c *gin.Context) {
+	var input dtos.VerifyTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewAuthService()
+	response, err := service.VerifyTwoFactor(input, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch {
+		case err.Error() == "Account is disabled":
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "Too many failed login attempts"):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		case err.Error() == "Invalid two-factor code", err.Error() == "Invalid or expired two-factor challenge":
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -28,10 +28,10 @@ func OpenCashSession(c *gin.Context) {
 	}
 
 	service := services.NewCashSessionService()
-	session, err := service.OpenCashSession(input, *userIDPtr)
+	session, err := service.OpenCashSession(input, *userIDPtr, common.GetActor(c))
 
 	if err != nil {
-		if err.Error() == "cash session masih terbuka" {
+		if err.Error() == "cash session masih terbuka" || err.Error() == "location not found" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -84,7 +84,7 @@ func CloseCashSession(c *gin.Context) {
 	}
 
 	service := services.NewCashSessionService()
-	session, err := service.CloseCashSession(input, *userIDPtr)
+	session, err := service.CloseCashSession(input, *userIDPtr, common.GetActor(c))
 
 	if err != nil {
 		if err.Error() == "tidak ada cash session terbuka" {
@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CreateGoodsReceipt(c *gin.Context) {
+	var input dtos.CreateGoodsReceiptInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewGoodsReceiptService()
+	receipt, err := service.CreateReceipt(input, common.GetActor(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, receipt)
+}
+
+func GetGoodsReceipts(c *gin.Context) {
+	var filter dtos.GoodsReceiptFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewGoodsReceiptService()
+	response, err := service.GetReceipts(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func GetGoodsReceiptByID(c *gin.Context) {
+	service := services.NewGoodsReceiptService()
+	receipt, err := service.GetReceiptByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, receipt)
+}
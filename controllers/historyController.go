@@ -4,6 +4,8 @@ import (
 	"kd-api/dtos"
 	"kd-api/services"
 	"kd-api/utils/common"
+	"kd-api/utils/permission"
+	"kd-api/utils/response"
 	"net/http"
 	"strconv"
 
@@ -21,7 +23,7 @@ func GetTransactions(c *gin.Context) {
         Page:  page,
         Limit: limit,
         Date:  filterDate,
-    })
+    }, common.GetUserRole(c))
 
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -41,7 +43,7 @@ func GetTransactionHistory(c *gin.Context) {
     response, err := service.GetTransactionHistory(dtos.TransactionFilter{
         Page:  page,
         Limit: limit,
-    })
+    }, common.GetUserRole(c))
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -56,21 +58,20 @@ func RefundTransaction(c *gin.Context) {
 	id := c.Param("id")
     service := services.NewTransactionService()
     
-    transaction, err := service.RefundTransaction(id, common.GetUserID(c), c.ClientIP())
+    transaction, err := service.RefundTransaction(id, common.GetActor(c), common.GetUserRole(c))
     if err != nil {
         if err.Error() == "transaction not found" {
             c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
             return
         }
-        if err.Error() == "only completed transactions can be refunded" {
-            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        if respondTransactionStatusError(c, err) {
             return
         }
         c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
         return
     }
 
-	c.JSON(http.StatusOK, transaction)
+	c.JSON(http.StatusOK, response.FilterTransaction(*transaction, common.HasPermission(c, permission.ItemsViewCost)))
 }
 
 func GetTransactionHistoryByDate(c *gin.Context) {
@@ -91,7 +92,7 @@ func GetTransactionHistoryByDate(c *gin.Context) {
         Page:  page,
         Limit: limit,
         Date:  filterDate, // Reusing Date field if StartDate logic handles it or adding simpler handling in service
-    })
+    }, common.GetUserRole(c))
 
      if err != nil {
         if err.Error() == "Invalid date format, use YYYY-MM-DD" {
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"kd-api/services"
+	"kd-api/utils/common"
+	"kd-api/utils/permission"
+	"kd-api/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Upload gambar item (multipart, field "image"), thumbnail dibuat otomatis
+func UploadItemImage(c *gin.Context) {
+	file, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required"})
+		return
+	}
+
+	service := services.NewImageService()
+	item, err := service.UploadItemImage(c.Param("id"), file, common.GetActor(c))
+	if err != nil {
+		switch {
+		case err.Error() == "Item not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "image too large"):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case strings.HasPrefix(err.Error(), "unsupported image type"),
+			err.Error() == "invalid image",
+			err.Error() == "image dimensions too large":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response.FilterItem(*item, common.HasPermission(c, permission.ItemsViewCost)))
+}
+
+func GetItemImage(c *gin.Context) {
+	serveItemImage(c, false)
+}
+
+func GetItemThumbnail(c *gin.Context) {
+	serveItemImage(c, true)
+}
+
+func serveItemImage(c *gin.Context, thumbnail bool) {
+	service := services.NewImageService()
+	body, contentType, err := service.OpenItemImage(c.Param("id"), thumbnail)
+	if err != nil {
+		if err.Error() == "Item not found" || err.Error() == "image not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	c.DataFromReader(http.StatusOK, -1, contentType, body, map[string]string{
+		"Cache-Control": "public, max-age=86400",
+	})
+}
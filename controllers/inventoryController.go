@@ -3,7 +3,9 @@ package controllers
 import (
 	"kd-api/dtos"
 	"kd-api/services"
+	"kd-api/utils/common"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,3 +26,37 @@ func GetInventoryHistory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+func GetInventoryReconciliation(c *gin.Context) {
+	var input dtos.ReconcileInventoryInput
+	if itemID, err := strconv.Atoi(c.Query("item_id")); err == nil && itemID > 0 {
+		id := uint(itemID)
+		input.ItemID = &id
+	}
+
+	service := services.NewInventoryService()
+	response, err := service.ReconcileInventory(input, common.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func ReconcileInventory(c *gin.Context) {
+	var input dtos.ReconcileInventoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewInventoryService()
+	response, err := service.ReconcileInventory(input, common.GetUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
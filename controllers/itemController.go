@@ -1,7 +1,11 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"net/http"
 
@@ -13,17 +17,112 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func GetItems(c *gin.Context) {
+// Filter dari query string, dipakai list admin, /public/items dan export:
+// name, category, low_stock, in_stock, min_price, max_price, min_stock, max_stock,
+// updated_since (YYYY-MM-DD atau RFC3339), sort=-best_selling,name
+func itemFilterFromQuery(c *gin.Context) (dtos.ItemFilter, error) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 
-	service := services.NewItemService()
-	response, err := service.GetItems(dtos.ItemFilter{
+	filter := dtos.ItemFilter{
 		Page:     page,
 		PageSize: pageSize,
-	}, common.GetUserRole(c))
+		Name:     c.Query("name"),
+		Category: c.Query("category"),
+		Sort:     c.Query("sort"),
+	}
+
+	var err error
+	if filter.LowStock, err = boolQuery(c, "low_stock"); err != nil {
+		return filter, err
+	}
+	if filter.InStock, err = boolQuery(c, "in_stock"); err != nil {
+		return filter, err
+	}
+	if filter.MinPrice, err = floatQuery(c, "min_price"); err != nil {
+		return filter, err
+	}
+	if filter.MaxPrice, err = floatQuery(c, "max_price"); err != nil {
+		return filter, err
+	}
+	if filter.MinStock, err = intQuery(c, "min_stock"); err != nil {
+		return filter, err
+	}
+	if filter.MaxStock, err = intQuery(c, "max_stock"); err != nil {
+		return filter, err
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return filter, errors.New("min_price cannot be above max_price")
+	}
+	if filter.MinStock != nil && filter.MaxStock != nil && *filter.MinStock > *filter.MaxStock {
+		return filter, errors.New("min_stock cannot be above max_stock")
+	}
+
+	if value := c.Query("updated_since"); value != "" {
+		since, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			since, err = time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				return filter, errors.New("Invalid date format, use YYYY-MM-DD or RFC3339")
+			}
+		}
+		filter.UpdatedSince = &since
+	}
+
+	return filter, nil
+}
+
+func boolQuery(c *gin.Context, key string) (bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", key)
+	}
+	return parsed, nil
+}
+
+func floatQuery(c *gin.Context, key string) (*float64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < 0 {
+		return nil, fmt.Errorf("%s must be a number, zero or more", key)
+	}
+	return &parsed, nil
+}
+
+func intQuery(c *gin.Context, key string) (*int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a whole number", key)
+	}
+	return &parsed, nil
+}
 
+func GetItems(c *gin.Context) {
+	filter, err := itemFilterFromQuery(c)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewItemService()
+	response, err := service.GetItems(filter, common.GetUserRole(c))
+
+	if err != nil {
+		if err.Error() == "invalid sort field" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -42,17 +141,20 @@ func GetItemsByName(c *gin.Context) {
 		return
 	}
 
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	filter, err := itemFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	service := services.NewItemService()
-	response, err := service.GetItems(dtos.ItemFilter{
-		Page:     page,
-		PageSize: pageSize,
-		Name:     name,
-	}, common.GetUserRole(c))
+	response, err := service.GetItems(filter, common.GetUserRole(c))
 
 	if err != nil {
+		if err.Error() == "invalid sort field" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -82,10 +184,12 @@ func CreateItem(c *gin.Context) {
 	}
 
 	service := services.NewItemService()
-	item, err := service.CreateItem(input, common.GetUserID(c), c.ClientIP(), common.GetUserRole(c))
+	item, err := service.CreateItem(input, common.GetActor(c), common.GetUserRole(c))
 	
 	if err != nil {
-		if err.Error() == "Item dengan nama ini sudah ada" {
+		if err.Error() == "Item dengan nama ini sudah ada" || err.Error() == "Item dengan SKU ini sudah ada" ||
+			err.Error() == "Item dengan barcode ini sudah ada" ||
+			err.Error() == "serial tracking can only be switched while the item has no stock" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -104,14 +208,20 @@ func UpdateItem(c *gin.Context) {
 	}
 
 	service := services.NewItemService()
-	item, err := service.UpdateItem(c.Param("id"), input, common.GetUserID(c), c.ClientIP(), common.GetUserRole(c))
+	item, err := service.UpdateItem(c.Param("id"), input, common.GetActor(c), common.GetUserRole(c))
 
 	if err != nil {
 		if err.Error() == "Item not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
-		if err.Error() == "Item dengan nama ini sudah ada" {
+		if err.Error() == "You do not have permission to change stock" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err.Error() == "Item dengan nama ini sudah ada" || err.Error() == "Item dengan SKU ini sudah ada" ||
+			err.Error() == "Item dengan barcode ini sudah ada" ||
+			err.Error() == "serial tracking can only be switched while the item has no stock" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -124,7 +234,7 @@ func UpdateItem(c *gin.Context) {
 
 func DeleteItem(c *gin.Context) {
 	service := services.NewItemService()
-	err := service.DeleteItem(c.Param("id"), common.GetUserID(c), c.ClientIP())
+	err := service.DeleteItem(c.Param("id"), common.GetActor(c))
 
 	if err != nil {
 		if err.Error() == "Item not found" {
@@ -138,6 +248,60 @@ func DeleteItem(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Item deleted successfully"})
 }
 
+// Daftar item yang sudah dihapus (recycle bin)
+func GetDeletedItems(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+
+	service := services.NewItemService()
+	result, err := service.GetDeletedItems(dtos.ItemFilter{
+		Page:     page,
+		PageSize: pageSize,
+		Name:     c.Query("name"),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Kembalikan item dari recycle bin
+func RestoreItem(c *gin.Context) {
+	service := services.NewItemService()
+	item, err := service.RestoreItem(c.Param("id"), common.GetActor(c), common.GetUserRole(c))
+	if err != nil {
+		if err.Error() == "Deleted item not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// Hapus permanen item yang ada di recycle bin (admin)
+func PurgeItem(c *gin.Context) {
+	service := services.NewItemService()
+	err := service.PurgeItem(c.Param("id"), common.GetActor(c))
+	if err != nil {
+		switch {
+		case err.Error() == "Deleted item not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case strings.HasSuffix(err.Error(), "cannot be purged"):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Item permanently deleted"})
+}
+
 func BulkCreateItems(c *gin.Context) {
 	var inputs []models.Item
 	if err := c.ShouldBindJSON(&inputs); err != nil {
@@ -146,7 +310,7 @@ func BulkCreateItems(c *gin.Context) {
 	}
 
 	service := services.NewItemService()
-	items, err := service.BulkCreateItems(inputs, common.GetUserID(c), c.ClientIP(), common.GetUserRole(c))
+	items, err := service.BulkCreateItems(inputs, common.GetActor(c), common.GetUserRole(c))
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -156,16 +320,58 @@ func BulkCreateItems(c *gin.Context) {
 	c.JSON(http.StatusCreated, items)
 }
 
+// Export item sesuai filter list, format=csv (default), xlsx atau json
 func ExportItems(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if strings.HasSuffix(c.FullPath(), "/export/csv") {
+		format = "csv"
+	}
+
+	filter, err := itemFilterFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	service := services.NewItemService()
-	export, err := service.ExportItems(common.GetUserRole(c))
+	export, err := service.ExportItems(filter, format, common.GetUserRole(c))
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.Header("Content-Description", "File Transfer")
 	c.Header("Content-Disposition", "attachment; filename=\"" + export.FileName + "\"")
-	c.Data(http.StatusOK, "text/csv", export.Content)
+	c.Header("Content-Type", export.ContentType)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, a failure halfway can only be logged
+	if err := export.Write(c.Writer); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// Import item dari file CSV/XLSX (kolom sama dengan export), dry_run=true hanya validasi
+func ImportItems(c *gin.Context) {
+	var options dtos.ItemImportOptions
+	if err := c.ShouldBind(&options); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	service := services.NewItemImportService()
+	report, err := service.ImportItems(file, options, common.GetActor(c), common.GetUserRole(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
 }
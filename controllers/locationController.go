@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetLocations(c *gin.Context) {
+	service := services.NewLocationService()
+	locations, err := service.GetLocations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, locations)
+}
+
+func CreateLocation(c *gin.Context) {
+	var input dtos.CreateLocationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewLocationService()
+	location, err := service.CreateLocation(input, common.GetActor(c))
+	if err != nil {
+		if err.Error() == "location already exists" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, location)
+}
+
+func UpdateLocation(c *gin.Context) {
+	var input dtos.CreateLocationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewLocationService()
+	location, err := service.UpdateLocation(c.Param("id"), input, common.GetActor(c))
+	if err != nil {
+		switch err.Error() {
+		case "location not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "location already exists", "set another location as default instead":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, location)
+}
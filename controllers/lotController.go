@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetLots(c *gin.Context) {
+	var filter dtos.LotFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewLotService()
+	response, err := service.GetLots(filter, common.GetUserRole(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Lots yang akan kadaluarsa dalam N hari (default 30), termasuk yang sudah lewat
+func GetExpiringLots(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be a positive number"})
+		return
+	}
+
+	service := services.NewLotService()
+	lots, err := service.GetExpiringLots(days, common.GetUserRole(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lots)
+}
+
+func GetLotTransactions(c *gin.Context) {
+	service := services.NewLotService()
+	transactions, err := service.GetLotTransactions(c.Param("id"))
+	if err != nil {
+		if err.Error() == "lot not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Riwayat harga item untuk grafik, harga beli hanya untuk admin
+func GetItemPriceHistory(c *gin.Context) {
+	var filter dtos.PriceHistoryFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewPriceService()
+	history, err := service.GetPriceHistory(c.Param("id"), filter, common.GetUserRole(c))
+	if err != nil {
+		switch err.Error() {
+		case "Item not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "Invalid date format, use YYYY-MM-DD":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func ScheduleItemPriceChange(c *gin.Context) {
+	var input dtos.SchedulePriceChangeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewPriceService()
+	change, err := service.SchedulePriceChange(c.Param("id"), input, common.GetActor(c))
+	if err != nil {
+		if err.Error() == "Item not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, change)
+}
+
+func CancelItemPriceChange(c *gin.Context) {
+	service := services.NewPriceService()
+	if err := service.CancelScheduledChange(c.Param("id"), c.Param("scheduleId"), common.GetActor(c)); err != nil {
+		if err.Error() == "scheduled change not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scheduled price change cancelled"})
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetReorderSuggestions(c *gin.Context) {
+	var filter dtos.ReorderFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewReorderService()
+	suggestions, err := service.GetSuggestions(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if filter.GroupBy == "supplier" {
+		groups, err := service.GroupBySupplier(suggestions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, groups)
+		return
+	}
+
+	c.JSON(http.StatusOK, suggestions)
+}
+
+func CreatePurchaseOrdersFromSuggestions(c *gin.Context) {
+	var input dtos.CreatePurchaseOrdersInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewReorderService()
+	orders, err := service.CreatePurchaseOrders(input, common.GetActor(c))
+	if err != nil {
+		if err.Error() == "nothing to reorder" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, orders)
+}
+
+func GetPurchaseOrders(c *gin.Context) {
+	service := services.NewReorderService()
+	orders, err := service.GetPurchaseOrders(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+func GetPurchaseOrderByID(c *gin.Context) {
+	service := services.NewReorderService()
+	order, err := service.GetPurchaseOrderByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, order)
+}
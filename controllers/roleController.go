@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+	"kd-api/utils/permission"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// roleErrorStatus memetakan error dari RoleService ke status HTTP
+func roleErrorStatus(err error) int {
+	switch {
+	case err.Error() == "Role not found":
+		return http.StatusNotFound
+	case strings.HasSuffix(err.Error(), "cannot be deleted"):
+		return http.StatusConflict
+	case err.Error() == "role already exists", err.Error() == "role name is required",
+		err.Error() == "system roles cannot be renamed", err.Error() == "the admin role always has every permission",
+		strings.HasPrefix(err.Error(), "unknown permission"), strings.HasPrefix(err.Error(), "role names cannot start"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Daftar permission yang bisa diberikan ke role
+func GetPermissions(c *gin.Context) {
+	c.JSON(http.StatusOK, permission.Catalog)
+}
+
+func GetRoles(c *gin.Context) {
+	service := services.NewRoleService()
+	roles, err := service.GetRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+func GetRoleByID(c *gin.Context) {
+	service := services.NewRoleService()
+	role, err := service.GetRoleByID(c.Param("id"))
+	if err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// Buat role baru, misalnya warehouse atau supervisor
+func CreateRole(c *gin.Context) {
+	var input dtos.CreateRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewRoleService()
+	role, err := service.CreateRole(input, common.GetActor(c))
+	if err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// Ubah nama, deskripsi atau permission role
+func UpdateRole(c *gin.Context) {
+	var input dtos.UpdateRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewRoleService()
+	role, err := service.UpdateRole(c.Param("id"), input, common.GetActor(c))
+	if err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// Hapus role yang tidak dipakai user mana pun
+func DeleteRole(c *gin.Context) {
+	service := services.NewRoleService()
+	if err := service.DeleteRole(c.Param("id"), common.GetActor(c)); err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kd-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Saran item untuk layar POS saat kasir mengetik (nama, SKU atau barcode)
+func AutocompleteItems(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusOK, gin.H{"data": []any{}})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	service := services.NewSearchService()
+	suggestions, err := service.Autocomplete(query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": suggestions})
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kd-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Cek nomor seri untuk klaim garansi: kapan diterima, dijual ke siapa, masa garansi
+func LookupSerial(c *gin.Context) {
+	service := services.NewSerialService()
+	serial, err := service.LookupSerial(c.Param("serial"))
+	if err != nil {
+		if err.Error() == "serial not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, serial)
+}
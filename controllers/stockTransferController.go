@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetStockTransfers(c *gin.Context) {
+	var filter dtos.StockTransferFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewStockTransferService()
+	response, err := service.GetTransfers(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func GetStockTransferByID(c *gin.Context) {
+	service := services.NewStockTransferService()
+	transfer, err := service.GetTransferByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+func CreateStockTransfer(c *gin.Context) {
+	var input dtos.CreateStockTransferInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewStockTransferService()
+	transfer, err := service.CreateTransfer(input, common.GetActor(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, transfer)
+}
+
+func ReceiveStockTransfer(c *gin.Context) {
+	service := services.NewStockTransferService()
+	transfer, err := service.ReceiveTransfer(c.Param("id"), common.GetActor(c))
+	respondStockTransfer(c, transfer, err)
+}
+
+func CancelStockTransfer(c *gin.Context) {
+	service := services.NewStockTransferService()
+	transfer, err := service.CancelTransfer(c.Param("id"), common.GetActor(c))
+	respondStockTransfer(c, transfer, err)
+}
+
+func respondStockTransfer(c *gin.Context, transfer interface{}, err error) {
+	if err != nil {
+		switch err.Error() {
+		case "transfer not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "transfer is not in transit":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
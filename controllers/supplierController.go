@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"net/http"
+
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetSuppliers(c *gin.Context) {
+	service := services.NewSupplierService()
+	suppliers, err := service.GetSuppliers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppliers)
+}
+
+func CreateSupplier(c *gin.Context) {
+	var input dtos.CreateSupplierInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewSupplierService()
+	supplier, err := service.CreateSupplier(input, common.GetActor(c))
+	if err != nil {
+		if err.Error() == "supplier already exists" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, supplier)
+}
+
+func UpdateSupplier(c *gin.Context) {
+	var input dtos.CreateSupplierInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewSupplierService()
+	supplier, err := service.UpdateSupplier(c.Param("id"), input, common.GetActor(c))
+	if err != nil {
+		switch err.Error() {
+		case "supplier not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "supplier already exists":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, supplier)
+}
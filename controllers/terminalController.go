@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetTerminals(c *gin.Context) {
+	service := services.NewTerminalService()
+	terminals, err := service.GetTerminals()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, terminals)
+}
+
+// Daftarkan terminal kasir, device token hanya ditampilkan sekali
+func RegisterTerminal(c *gin.Context) {
+	var input dtos.RegisterTerminalInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewTerminalService()
+	registration, err := service.RegisterTerminal(input, common.GetActor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, registration)
+}
+
+// Cabut terminal, device token tidak berlaku dan session di terminal itu berakhir
+func RevokeTerminal(c *gin.Context) {
+	service := services.NewTerminalService()
+	if err := service.RevokeTerminal(c.Param("id"), common.GetActor(c)); err != nil {
+		switch err.Error() {
+		case "Terminal not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case "terminal is already revoked":
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Terminal revoked"})
+}
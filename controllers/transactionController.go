@@ -1,11 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
 	"kd-api/dtos"
 	"kd-api/services"
 	"kd-api/utils/common"
+	"kd-api/utils/permission"
+	"kd-api/utils/response"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,19 +22,44 @@ func CreateTransaction(c *gin.Context) {
 		return
 	}
 
+	input.TerminalID = common.GetTerminalID(c)
+
 	service := services.NewTransactionService()
-	transaction, warnings, err := service.CreateTransaction(input, common.GetUserID(c), c.ClientIP())
+	transaction, warnings, err := service.CreateTransaction(input, common.GetActor(c))
 	if err != nil {
+		if respondTransactionStatusError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	response := gin.H{"transaction": transaction}
+	body := gin.H{"transaction": response.FilterTransaction(*transaction, common.HasPermission(c, permission.ItemsViewCost))}
 	if len(warnings) > 0 {
-		response["warnings"] = warnings
+		body["warnings"] = warnings
 	}
 
-	c.JSON(http.StatusCreated, response)
+	c.JSON(http.StatusCreated, body)
+}
+
+// Status HTTP untuk tiap kode error status transaksi
+var transactionErrorStatus = map[string]int{
+	services.TransactionErrInvalidStatus:     http.StatusBadRequest,
+	services.TransactionErrIllegalTransition: http.StatusConflict,
+	services.TransactionErrPermissionDenied:  http.StatusForbidden,
+	services.TransactionErrLocked:            http.StatusConflict,
+	services.TransactionErrStockReserved:     http.StatusConflict,
+}
+
+// respondTransactionStatusError mengirim error perubahan status beserta kodenya,
+// false jika err bukan error status
+func respondTransactionStatusError(c *gin.Context, err error) bool {
+	var statusErr *services.TransactionStatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	c.JSON(transactionErrorStatus[statusErr.Code], gin.H{"error": statusErr.Message, "code": statusErr.Code})
+	return true
 }
 
 func UpdateTransactionStatus(c *gin.Context) {
@@ -44,18 +72,20 @@ func UpdateTransactionStatus(c *gin.Context) {
 	}
 
 	service := services.NewTransactionService()
-	transaction, err := service.UpdateTransactionStatus(id, input, common.GetUserID(c), c.ClientIP())
+	transaction, err := service.UpdateTransactionStatus(id, input, common.GetActor(c), common.GetUserRole(c))
 	if err != nil {
-		// Distinguish between not found and other errors if needed, but for now generic 500 or 400
 		if err.Error() == "transaction not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		if respondTransactionStatusError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	c.JSON(http.StatusOK, response.FilterTransaction(*transaction, common.HasPermission(c, permission.ItemsViewCost)))
 }
 
 func GetDraftTransactions(c *gin.Context) {
@@ -69,7 +99,7 @@ func GetDraftTransactions(c *gin.Context) {
 		Status: status,
 		Page:   1, // Default or query param
 		Limit:  100, // Or query param, assuming fetch all drafts usually
-	})
+	}, common.GetUserRole(c))
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -84,14 +114,13 @@ func DeleteTransaction(c *gin.Context) {
 	id := c.Param("id")
 	service := services.NewTransactionService()
 	
-	err := service.DeleteDraft(id, common.GetUserID(c), c.ClientIP())
+	err := service.DeleteDraft(id, common.GetActor(c), common.GetUserRole(c))
 	if err != nil {
 		if err.Error() == "transaction not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
-		if err.Error() == "only draft can be deleted" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if respondTransactionStatusError(c, err) {
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -112,7 +141,7 @@ func GetTransactionByID(c *gin.Context) {
 	}
 
 	if transaction.Status == "draft" {
-		c.JSON(http.StatusOK, transaction)
+		c.JSON(http.StatusOK, response.FilterTransaction(*transaction, common.HasPermission(c, permission.ItemsViewCost)))
 		// Keeping the logic to delete draft after view? That seems odd in the original code but preserving behavior.
 		// Wait, original code:
 		// go func() { config.DB.Delete(&models.Transaction{}, id) }()
@@ -120,10 +149,10 @@ func GetTransactionByID(c *gin.Context) {
 		// I will replicate this behavior via service if needed, but maybe I should expose a method for it.
 		// For now I'll call DeleteDraft in a goroutine ignoring errors as per original fire-and-forget.
 		go func() {
-			_ = service.DeleteDraft(id, common.GetUserID(c), c.ClientIP())
+			_ = service.DeleteDraft(id, common.GetActor(c), common.GetUserRole(c))
 		}()
 		return
 	}
 
-	c.JSON(http.StatusOK, transaction)
+	c.JSON(http.StatusOK, response.FilterTransaction(*transaction, common.HasPermission(c, permission.ItemsViewCost)))
 }
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// twoFactorErrorStatus memetakan error dari TwoFactorService ke status HTTP
+func twoFactorErrorStatus(err error) int {
+	switch err.Error() {
+	case "Current password is incorrect", "Invalid two-factor code":
+		return http.StatusUnauthorized
+	case "User not found":
+		return http.StatusNotFound
+	case "two-factor authentication is already enabled", "two-factor authentication is not enabled",
+		"two-factor authentication has not been set up":
+		return http.StatusBadRequest
+	case "two-factor authentication is required for your role":
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Buat secret 2FA baru, aktif setelah dikonfirmasi lewat /me/2fa/enable
+func SetupTwoFactor(c *gin.Context) {
+	var input dtos.TwoFactorSetupInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := common.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	service := services.NewTwoFactorService()
+	response, err := service.Setup(*userID, input)
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Aktifkan 2FA dengan kode dari authenticator, recovery code hanya ditampilkan sekali
+func EnableTwoFactor(c *gin.Context) {
+	var input dtos.TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := common.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	service := services.NewTwoFactorService()
+	response, err := service.Enable(*userID, input, common.GetActor(c))
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Nonaktifkan 2FA, dikonfirmasi dengan password dan kode
+func DisableTwoFactor(c *gin.Context) {
+	var input dtos.DisableTwoFactorInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := common.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	service := services.NewTwoFactorService()
+	if err := service.Disable(*userID, input, common.GetActor(c)); err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// Buat recovery code baru, yang lama tidak berlaku lagi
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var input dtos.TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := common.GetUserID(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	service := services.NewTwoFactorService()
+	response, err := service.RegenerateRecoveryCodes(*userID, input, common.GetActor(c))
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
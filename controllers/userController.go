@@ -1,20 +1,154 @@
 package controllers
 
 import (
-	"kd-api/config"
-	"kd-api/models"
+	"kd-api/dtos"
+	"kd-api/services"
+	"kd-api/utils/common"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 func GetUsers(c *gin.Context) {
-	var users []models.User
-	
-	if err := config.DB.Select("id, username, role").Find(&users).Error; err != nil {
+	service := services.NewUserService()
+	users, err := service.GetUsers()
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, users)
-}
\ No newline at end of file
+}
+
+// userErrorStatus memetakan error dari UserService ke status HTTP
+func userErrorStatus(err error) int {
+	switch {
+	case err.Error() == "User not found":
+		return http.StatusNotFound
+	case err.Error() == "username already exists", err.Error() == "at least one active admin is required",
+		err.Error() == "you cannot disable your own account", err.Error() == "role not found",
+		strings.HasPrefix(err.Error(), "password "):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Buat user baru (password di-hash dengan bcrypt)
+func CreateUser(c *gin.Context) {
+	var input dtos.CreateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewUserService()
+	user, err := service.CreateUser(input, common.GetActor(c))
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// Ubah username atau role
+func UpdateUser(c *gin.Context) {
+	var input dtos.UpdateUserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewUserService()
+	user, err := service.UpdateUser(c.Param("id"), input, common.GetActor(c))
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// Nonaktifkan akun, user tidak bisa login dan token lamanya ditolak
+func DisableUser(c *gin.Context) {
+	setUserActive(c, false)
+}
+
+func EnableUser(c *gin.Context) {
+	setUserActive(c, true)
+}
+
+func setUserActive(c *gin.Context, active bool) {
+	service := services.NewUserService()
+	user, err := service.SetUserActive(c.Param("id"), active, common.GetActor(c))
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// Reset password user oleh admin
+func ResetUserPassword(c *gin.Context) {
+	var input dtos.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	service := services.NewUserService()
+	if err := service.ResetPassword(c.Param("id"), input, common.GetActor(c)); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// Daftar session aktif milik user
+func GetUserSessions(c *gin.Context) {
+	service := services.NewUserService()
+	sessions, err := service.GetSessions(c.Param("id"))
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// Paksa logout user dari semua perangkat
+func KillUserSessions(c *gin.Context) {
+	service := services.NewUserService()
+	if err := service.KillSessions(c.Param("id"), common.GetActor(c)); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
+}
+
+// Hapus PIN user yang lupa PIN-nya, session terminalnya ikut berakhir
+func ClearUserPin(c *gin.Context) {
+	service := services.NewUserService()
+	if err := service.ClearPin(c.Param("id"), common.GetActor(c)); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "PIN cleared"})
+}
+
+// Reset 2FA user yang kehilangan authenticator dan recovery code-nya
+func ResetUserTwoFactor(c *gin.Context) {
+	service := services.NewTwoFactorService()
+	if err := service.Reset(c.Param("id"), common.GetActor(c)); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
@@ -0,0 +1,16 @@
+package dtos
+
+import "kd-api/models"
+
+type CreateAPIKeyInput struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Permissions   []string `json:"permissions" binding:"required"`
+	ExpiresInDays *int     `json:"expires_in_days" binding:"omitempty,min=1"` // omitted for keys that do not expire
+}
+
+// APIKeyCreated carries the key, shown only this once. Integrations send it in the
+// X-API-Key header instead of a bearer token.
+type APIKeyCreated struct {
+	APIKey models.APIKey `json:"api_key"`
+	Key    string        `json:"key"`
+}
@@ -1,12 +1,59 @@
 package dtos
 
+import "kd-api/models"
+
 type LoginInput struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
 
 type AuthResponse struct {
-	Message string `json:"message"`
-	Token   string `json:"token"`
-	Role    string `json:"role"`
+	Message            string `json:"message"`
+	Token              string `json:"token"`         // short-lived access token
+	RefreshToken       string `json:"refresh_token"` // exchanged at POST /auth/refresh, single use
+	ExpiresIn          int    `json:"expires_in"`    // access token lifetime in seconds
+	Role               string `json:"role"`
+	MustChangePassword bool   `json:"must_change_password,omitempty"` // only POST /me/password is allowed until changed
+	TerminalID         *uint  `json:"terminal_id,omitempty"`          // set for PIN sessions
+
+	// With two-factor authentication on, login returns only a challenge token to
+	// send with the code to POST /auth/2fa/verify
+	TwoFactorRequired      bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken         string `json:"challenge_token,omitempty"`
+	TwoFactorSetupRequired bool   `json:"two_factor_setup_required,omitempty"` // the role requires 2FA, only the setup endpoints work until it is on
+}
+
+type VerifyTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // authenticator code or a recovery code
+}
+
+// PinLoginInput logs a user in on a registered terminal, sent with the terminal's
+// device token in the X-Terminal-Token header.
+type PinLoginInput struct {
+	Username string `json:"username" binding:"required"`
+	Pin      string `json:"pin" binding:"required"`
+}
+
+type UnlockSessionInput struct {
+	Pin string `json:"pin" binding:"required"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LoginAttemptFilter struct {
+	Page      int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+	Username  string `form:"username"`
+	IPAddress string `form:"ip"`
+	Success   *bool  `form:"success"`
+	StartDate string `form:"start_date"` // YYYY-MM-DD
+	EndDate   string `form:"end_date"`   // YYYY-MM-DD
+}
+
+type LoginAttemptListResponse struct {
+	Data []models.LoginAttempt `json:"data"`
+	Meta PaginationMeta        `json:"meta"`
 }
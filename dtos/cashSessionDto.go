@@ -6,6 +6,7 @@ import (
 
 type OpenCashSessionInput struct {
 	OpeningCash float64 `json:"opening_cash" binding:"required"`
+	LocationID  *uint   `json:"location_id"` // selling location, defaults to the default location
 }
 
 type CloseCashSessionInput struct {
@@ -2,21 +2,197 @@ package dtos
 
 import (
 	"kd-api/models"
+	"time"
 )
 
 type InventoryFilter struct {
-	ItemID    uint   `form:"item_id" binding:"required"`
+	ItemID     uint   `form:"item_id" binding:"required"`
+	StartDate  string `form:"start_date"` // YYYY-MM-DD
+	EndDate    string `form:"end_date"`   // YYYY-MM-DD
+	Type       string `form:"type"`       // specific type filter
+	LocationID uint   `form:"location_id"`
+	LotID      uint   `form:"lot_id"`
+	Page       int    `form:"page"`
+	Limit      int    `form:"limit"`
+}
+
+type InventoryListResponse struct {
+	Data       []models.InventoryLog `json:"data"`
+	Page       int                   `json:"page"`
+	Limit      int                   `json:"limit"`
+	Total      int64                 `json:"total"`
+	TotalPages int                   `json:"total_pages"`
+}
+
+type ReconcileInventoryInput struct {
+	ItemID *uint `json:"item_id"`
+	Fix    bool  `json:"fix"`
+}
+
+type InventoryDiscrepancy struct {
+	ItemID      uint   `json:"item_id"`
+	Name        string `json:"name"`
+	Stock       int    `json:"stock"`        // Item.Stock
+	LedgerStock int    `json:"ledger_stock"` // SUM(inventory_logs.change)
+	Difference  int    `json:"difference"`   // Stock - LedgerStock
+	Entries     int64  `json:"entries"`
+	Fixed       bool   `json:"fixed"`
+}
+
+type InventoryReconcileResponse struct {
+	CheckedItems  int64                  `json:"checked_items"`
+	Discrepancies []InventoryDiscrepancy `json:"discrepancies"`
+	Fixed         int                    `json:"fixed"`
+}
+
+type CreateLocationInput struct {
+	Name      string `json:"name" binding:"required"`
+	Code      string `json:"code" binding:"required"`
+	IsDefault bool   `json:"is_default"`
+}
+
+type StockTransferItemInput struct {
+	ItemID   uint `json:"item_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"required,gt=0"`
+}
+
+type CreateStockTransferInput struct {
+	FromLocationID uint                     `json:"from_location_id" binding:"required"`
+	ToLocationID   uint                     `json:"to_location_id" binding:"required"`
+	Note           *string                  `json:"note"`
+	Items          []StockTransferItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+type StockTransferFilter struct {
+	Status     string `form:"status"`
+	LocationID uint   `form:"location_id"` // matches either side of the transfer
+	Page       int    `form:"page"`
+	Limit      int    `form:"limit"`
+}
+
+type StockTransferListResponse struct {
+	Data       []models.StockTransfer `json:"data"`
+	Page       int                    `json:"page"`
+	Limit      int                    `json:"limit"`
+	Total      int64                  `json:"total"`
+	TotalPages int                    `json:"total_pages"`
+}
+
+type GoodsReceiptItemInput struct {
+	ItemID   uint    `json:"item_id" binding:"required"`
+	Quantity int     `json:"quantity" binding:"required,gt=0"`
+	UnitCost float64 `json:"unit_cost" binding:"gte=0"`
+
+	// Optional lot tracking
+	BatchNumber *string `json:"batch_number"`
+	ExpiryDate  *string `json:"expiry_date"` // YYYY-MM-DD
+
+	Serials []string `json:"serials"` // one per unit, required for serialized items
+}
+
+type CreateGoodsReceiptInput struct {
+	LocationID  *uint                   `json:"location_id"`
+	ReferenceNo *string                 `json:"reference_no"`
+	Note        *string                 `json:"note"`
+	Items       []GoodsReceiptItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+type GoodsReceiptFilter struct {
 	StartDate string `form:"start_date"` // YYYY-MM-DD
 	EndDate   string `form:"end_date"`   // YYYY-MM-DD
-	Type      string `form:"type"`       // specific type filter
 	Page      int    `form:"page"`
 	Limit     int    `form:"limit"`
 }
 
-type InventoryListResponse struct {
-	Data       []models.InventoryLog `json:"data"`
+type GoodsReceiptListResponse struct {
+	Data       []models.GoodsReceipt `json:"data"`
 	Page       int                   `json:"page"`
 	Limit      int                   `json:"limit"`
 	Total      int64                 `json:"total"`
 	TotalPages int                   `json:"total_pages"`
 }
+
+type LotFilter struct {
+	ItemID      uint   `form:"item_id"`
+	LocationID  uint   `form:"location_id"`
+	BatchNumber string `form:"batch_number"`
+	InStockOnly bool   `form:"in_stock_only"`
+	Page        int    `form:"page"`
+	Limit       int    `form:"limit"`
+}
+
+type LotListResponse struct {
+	Data       interface{} `json:"data"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	Total      int64       `json:"total"`
+	TotalPages int         `json:"total_pages"`
+}
+
+type ExpiringLot struct {
+	models.Lot
+	DaysLeft int  `json:"days_left"`
+	Expired  bool `json:"expired"`
+}
+
+type LotTransaction struct {
+	TransactionID uint      `json:"transaction_id"`
+	Status        string    `json:"status"`
+	Quantity      int       `json:"quantity"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type ReorderSuggestion struct {
+	ItemID        uint    `json:"item_id"`
+	Name          string  `json:"name"`
+	SupplierID    *uint   `json:"supplier_id,omitempty"`
+	Stock         int     `json:"stock"`
+	OnOrder       int     `json:"on_order"`       // in draft/ordered purchase orders
+	SoldInPeriod  int     `json:"sold_in_period"` // completed sales over the lookback period
+	DailyVelocity float64 `json:"daily_velocity"`
+	LeadTimeDays  int     `json:"lead_time_days"`
+	ReorderPoint  int     `json:"reorder_point"`
+	SuggestedQty  int     `json:"suggested_qty"`
+	UnitCost      float64 `json:"unit_cost"`
+}
+
+type SupplierReorderGroup struct {
+	SupplierID     *uint               `json:"supplier_id,omitempty"`
+	SupplierName   string              `json:"supplier_name"`
+	LeadTimeDays   int                 `json:"lead_time_days"`
+	Items          []ReorderSuggestion `json:"items"`
+	EstimatedTotal float64             `json:"estimated_total"`
+}
+
+type ReorderFilter struct {
+	Days    int    `form:"days"`     // sales lookback, default 30
+	GroupBy string `form:"group_by"` // "supplier" to group by supplier
+}
+
+type CreatePurchaseOrdersInput struct {
+	Days    int    `json:"days"`
+	ItemIDs []uint `json:"item_ids"` // limit to these suggestions, empty means all
+}
+
+type CreateSupplierInput struct {
+	Name         string  `json:"name" binding:"required"`
+	Phone        *string `json:"phone"`
+	Email        *string `json:"email"`
+	LeadTimeDays int     `json:"lead_time_days" binding:"gte=0"`
+}
+
+type SerialLookupResponse struct {
+	Serial            string     `json:"serial"`
+	Status            string     `json:"status"`
+	ItemID            uint       `json:"item_id"`
+	ItemName          string     `json:"item_name"`
+	GoodsReceiptID    *uint      `json:"goods_receipt_id,omitempty"`
+	ReceivedAt        time.Time  `json:"received_at"`
+	TransactionID     *uint      `json:"transaction_id,omitempty"`
+	SoldAt            *time.Time `json:"sold_at,omitempty"`
+	CustomerName      *string    `json:"customer_name,omitempty"`
+	CustomerPhone     *string    `json:"customer_phone,omitempty"`
+	WarrantyMonths    int        `json:"warranty_months"`
+	WarrantyExpiresAt *time.Time `json:"warranty_expires_at,omitempty"`
+	UnderWarranty     bool       `json:"under_warranty"`
+}
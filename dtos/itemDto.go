@@ -1,7 +1,9 @@
 package dtos
 
 import (
+	"io"
 	"kd-api/models"
+	"time"
 )
 
 type itemResponseData interface{}
@@ -13,31 +15,157 @@ type ItemListResponse struct {
 
 type CreateItemInput struct {
 	Name        string  `json:"name" binding:"required"`
+	SKU         *string `json:"sku"`
+	Barcode     *string `json:"barcode"`
 	Description *string `json:"description"`
+	Category    *string `json:"category"`
 	Stock       int     `json:"stock"`
 	BuyPrice    float64 `json:"buy_price"`
 	Price       float64 `json:"price" binding:"required"`
 	ImageURL    *string `json:"image_url"`
+	LocationID  *uint   `json:"location_id"` // where the initial stock is placed
+
+	SupplierID   *uint `json:"supplier_id"`
+	MinStock     int   `json:"min_stock" binding:"gte=0"`
+	ReorderPoint int   `json:"reorder_point" binding:"gte=0"`
+	ReorderQty   int   `json:"reorder_qty" binding:"gte=0"`
+
+	IsSerialized   bool     `json:"is_serialized"`
+	WarrantyMonths int      `json:"warranty_months" binding:"gte=0"`
+	Serials        []string `json:"serials"` // one per unit of initial stock for serialized items
+
+	Components []KitComponentInput `json:"components" binding:"dive"` // makes the item a kit
+}
+
+type KitComponentInput struct {
+	ItemID   uint `json:"item_id" binding:"required"`
+	Quantity int  `json:"quantity" binding:"required,gt=0"`
 }
 
 type UpdateItemInput struct {
 	Name        string  `json:"name"`
+	SKU         *string `json:"sku"`     // left unchanged when omitted, empty clears it
+	Barcode     *string `json:"barcode"` // left unchanged when omitted, empty clears it
 	Description *string `json:"description"`
-	Stock       int     `json:"stock"`
+	Category    *string `json:"category"` // left unchanged when omitted, empty clears it
+	Stock       *int    `json:"stock"`    // left unchanged when omitted
 	BuyPrice    float64 `json:"buy_price"`
 	Price       float64 `json:"price"`
 	ImageURL    *string `json:"image_url"`
+	LocationID  *uint   `json:"location_id"` // where a stock change is applied
+
+	// Replenishment settings are left unchanged when omitted
+	SupplierID   *uint `json:"supplier_id"`
+	MinStock     *int  `json:"min_stock" binding:"omitempty,gte=0"`
+	ReorderPoint *int  `json:"reorder_point" binding:"omitempty,gte=0"`
+	ReorderQty   *int  `json:"reorder_qty" binding:"omitempty,gte=0"`
+
+	IsSerialized   *bool `json:"is_serialized"`
+	WarrantyMonths *int  `json:"warranty_months" binding:"omitempty,gte=0"`
+
+	// Replaces the kit's components when given, an empty list turns the kit back into a normal item
+	Components *[]KitComponentInput `json:"components" binding:"omitempty,dive"`
 }
 
 type ItemFilter struct {
 	Page     int
 	PageSize int
-	Name     string
+	Name     string // search text, see applyItemFilter
+	Category string
+	LowStock bool
+	InStock  bool // only items that can be sold right now
+
+	MinPrice     *float64
+	MaxPrice     *float64
+	MinStock     *int
+	MaxStock     *int
+	UpdatedSince *time.Time
+
+	Sort string // comma separated field names, each prefixed with "-" for descending
+}
+
+// DeletedItemResponse is a recycle bin entry.
+type DeletedItemResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	SKU       *string   `json:"sku"`
+	Category  *string   `json:"category"`
+	Price     float64   `json:"price"`
+	IsKit     bool      `json:"is_kit"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// ItemSuggestion is an autocomplete entry for the POS screen.
+type ItemSuggestion struct {
+	ID           uint    `json:"id"`
+	Name         string  `json:"name"`
+	SKU          *string `json:"sku,omitempty"`
+	Barcode      *string `json:"barcode,omitempty"`
+	Price        float64 `json:"price"`
+	Available    int     `json:"available"`
+	ThumbnailURL *string `json:"thumbnail_url,omitempty"`
+	IsKit        bool    `json:"is_kit"`
 }
 
-type CSVExport struct {
-	FileName string
-	Content  []byte
+// ItemExport is a file written straight to the response by Write.
+type ItemExport struct {
+	FileName    string
+	ContentType string
+	Write       func(w io.Writer) error
 }
 
 type BulkCreateItemInput []models.Item
+
+type PriceHistoryFilter struct {
+	StartDate string `form:"start_date"` // YYYY-MM-DD
+	EndDate   string `form:"end_date"`   // YYYY-MM-DD
+}
+
+// PriceHistoryEntry is one point of the price chart. Buy prices are left out for cashiers.
+type PriceHistoryEntry struct {
+	Date        time.Time `json:"date"`
+	OldPrice    float64   `json:"old_price"`
+	Price       float64   `json:"price"`
+	OldBuyPrice *float64  `json:"old_buy_price,omitempty"`
+	BuyPrice    *float64  `json:"buy_price,omitempty"`
+	Source      string    `json:"source"`
+}
+
+type PriceHistoryResponse struct {
+	ItemID   uint                          `json:"item_id"`
+	Name     string                        `json:"name"`
+	History  []PriceHistoryEntry           `json:"history"`
+	Upcoming []models.ScheduledPriceChange `json:"upcoming,omitempty"` // pending scheduled changes, needs items.view_cost
+}
+
+type SchedulePriceChangeInput struct {
+	Price       *float64 `json:"price" binding:"omitempty,gt=0"`
+	BuyPrice    *float64 `json:"buy_price" binding:"omitempty,gte=0"`
+	EffectiveAt string   `json:"effective_at" binding:"required"` // RFC3339 or YYYY-MM-DD (start of day)
+	Note        *string  `json:"note"`
+}
+
+type ItemImportOptions struct {
+	DryRun     bool   `form:"dry_run"`
+	Mode       string `form:"mode" binding:"omitempty,oneof=create upsert"` // create (default) only adds items, upsert also updates matches
+	LocationID *uint  `form:"location_id"`                                  // where stock changes are applied
+}
+
+type ItemImportRowResult struct {
+	Row    int      `json:"row"`    // line in the file, the header is row 1
+	Action string   `json:"action"` // create, update, unchanged or error
+	ItemID *uint    `json:"item_id,omitempty"`
+	Name   string   `json:"name,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type ItemImportReport struct {
+	DryRun    bool                  `json:"dry_run"`
+	Mode      string                `json:"mode"`
+	Total     int                   `json:"total"`
+	Created   int                   `json:"created"`
+	Updated   int                   `json:"updated"`
+	Unchanged int                   `json:"unchanged"`
+	Failed    int                   `json:"failed"`
+	Rows      []ItemImportRowResult `json:"rows"`
+}
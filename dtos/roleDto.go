@@ -0,0 +1,18 @@
+package dtos
+
+type CreateRoleInput struct {
+	Name        string   `json:"name" binding:"required,max=50"`
+	Description string   `json:"description" binding:"max=255"`
+	Permissions []string `json:"permissions" binding:"required"`
+	// Users of the role must set up two-factor authentication
+	RequireTwoFactor bool `json:"require_two_factor"`
+}
+
+// UpdateRoleInput changes a role, omitted fields are left unchanged. Permissions
+// replaces the whole list.
+type UpdateRoleInput struct {
+	Name             *string  `json:"name" binding:"omitempty,max=50"`
+	Description      *string  `json:"description" binding:"omitempty,max=255"`
+	Permissions      []string `json:"permissions"`
+	RequireTwoFactor *bool    `json:"require_two_factor"`
+}
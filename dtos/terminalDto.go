@@ -0,0 +1,14 @@
+package dtos
+
+import "kd-api/models"
+
+type RegisterTerminalInput struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// TerminalRegistration carries the device token, shown only this once. The
+// terminal sends it in the X-Terminal-Token header when a user logs in with a PIN.
+type TerminalRegistration struct {
+	Terminal    models.Terminal `json:"terminal"`
+	DeviceToken string          `json:"device_token"`
+}
@@ -1,11 +1,10 @@
 package dtos
 
-import "kd-api/models"
-
 type TransactionItemInput struct {
 	ItemID      uint     `json:"item_id"`
 	Quantity    int      `json:"quantity"`
 	CustomPrice *float64 `json:"customPrice,omitempty"`
+	Serials     []string `json:"serials,omitempty"` // required for serialized items on completion
 }
 
 type CreateTransactionInput struct {
@@ -15,7 +14,11 @@ type CreateTransactionInput struct {
 	Note            *string                `json:"note,omitempty"`
 	TransactionType *string                `json:"transaction_type,omitempty"`
 	Discount        *float64               `json:"discount,omitempty"`
+	LocationID      *uint                  `json:"location_id,omitempty"` // defaults to the cash session / default location
+	CustomerName    *string                `json:"customer_name,omitempty"`
+	CustomerPhone   *string                `json:"customer_phone,omitempty"`
 	Items           []TransactionItemInput `json:"items"`
+	TerminalID      *uint                  `json:"-"` // set from the PIN session, not by the client
 }
 
 type UpdateTransactionInput struct {
@@ -36,9 +39,9 @@ type TransactionFilter struct {
 
 
 type TransactionListResponse struct {
-	Data       []models.Transaction `json:"data"`
-	Page       int                  `json:"page"`
-	Limit      int                  `json:"limit"`
-	Total      int64                `json:"total"`
-	TotalPages int                  `json:"totalPages"`
+	Data       interface{} `json:"data"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	Total      int64       `json:"total"`
+	TotalPages int         `json:"totalPages"`
 }
@@ -0,0 +1,26 @@
+package dtos
+
+type TwoFactorSetupInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+// TwoFactorSetupResponse carries the new secret. Clients show ProvisioningURI as a
+// QR code for authenticator apps, Secret is for typing it in by hand.
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code" binding:"required"` // authenticator code, or a recovery code where accepted
+}
+
+type DisableTwoFactorInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	Code            string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse lists the single-use recovery codes, shown only this once.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
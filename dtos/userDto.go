@@ -0,0 +1,27 @@
+package dtos
+
+type CreateUserInput struct {
+	Username string `json:"username" binding:"required,min=3,max=50"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// UpdateUserInput changes the username or role, omitted fields are left unchanged.
+type UpdateUserInput struct {
+	Username *string `json:"username" binding:"omitempty,min=3,max=50"`
+	Role     *string `json:"role" binding:"omitempty"`
+}
+
+type ResetPasswordInput struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type SetPinInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	Pin             string `json:"pin" binding:"required"`
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
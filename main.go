@@ -3,6 +3,7 @@ package main
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -10,6 +11,7 @@ import (
 
 	"kd-api/config"
 	"kd-api/routes"
+	"kd-api/services"
 )
 
 func main() {
@@ -21,6 +23,11 @@ func main() {
 	// Connect DB
 	config.ConnectDatabase()
 
+	// Background jobs
+	services.StartReservationExpiry(time.Minute)
+	services.StartPriceScheduler(time.Minute)
+	services.StartSearchIndexRefresh(10 * time.Minute)
+
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
@@ -30,7 +37,7 @@ func main() {
 			"https://klampisdepo.com",
 		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-Terminal-Token", "X-API-Key"},
 		AllowCredentials: true,
 	}))
 
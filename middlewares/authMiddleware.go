@@ -1,14 +1,47 @@
 package middlewares
 
 import (
+	"fmt"
 	"net/http"
+	"reflect"
+	"runtime"
 	"strings"
+	"time"
 
+	"kd-api/config"
+	"kd-api/models"
+	"kd-api/services"
 	"kd-api/utils"
+	"kd-api/utils/common"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Endpoint yang tetap bisa dipakai selama user wajib ganti password
+var passwordChangeAllowedPaths = map[string]bool{
+	"/me/password":     true,
+	"/auth/logout":     true,
+	"/auth/logout-all": true,
+}
+
+// Endpoint yang tetap bisa dipakai saat session terminal terkunci
+var lockedSessionAllowedPaths = map[string]bool{
+	"/auth/unlock": true,
+	"/auth/logout": true,
+}
+
+// Endpoint yang tetap bisa dipakai sebelum 2FA yang diwajibkan role diaktifkan
+var twoFactorSetupAllowedPaths = map[string]bool{
+	"/me/2fa/setup":    true,
+	"/me/2fa/enable":   true,
+	"/me/password":     true,
+	"/auth/logout":     true,
+	"/auth/logout-all": true,
+}
+
+// Last use of a terminal session is written at most this often
+const sessionTouchInterval = 15 * time.Second
+
 // AuthMiddleware untuk memeriksa JWT
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -19,6 +52,11 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		authHeader := c.GetHeader("Authorization")
+		// Integrasi memakai API key sebagai pengganti JWT
+		if apiKey := c.GetHeader("X-API-Key"); authHeader == "" && apiKey != "" {
+			authenticateAPIKey(c, apiKey)
+			return
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
 			c.Abort()
@@ -33,16 +71,144 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Akun yang dinonaktifkan tidak boleh memakai token lamanya
+		var user models.User
+		if err := config.DB.Select("id", "role", "is_active", "token_version", "must_change_password", "totp_enabled").
+			First(&user, claims.UserID).Error; err != nil || !user.IsActive {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is disabled or no longer exists"})
+			c.Abort()
+			return
+		}
+
+		// Token dari sebelum ganti password sudah tidak berlaku
+		if claims.TokenVersion != user.TokenVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked, please log in again"})
+			c.Abort()
+			return
+		}
+
+		// Session yang sudah logout atau dicabut admin
+		var session models.Session
+		if claims.SessionID != 0 {
+			if err := config.DB.Select("id", "revoked_at", "terminal_id", "locked_at", "last_used_at").
+				First(&session, claims.SessionID).Error; err != nil || session.RevokedAt != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, please log in again"})
+				c.Abort()
+				return
+			}
+		}
+
+		// Session PIN di terminal terkunci jika terlalu lama tidak dipakai
+		if session.TerminalID != nil {
+			now := time.Now()
+			if session.LockedAt == nil && now.Sub(session.LastUsedAt) > utils.TerminalIdleTimeout() {
+				session.LockedAt = &now
+				config.DB.Model(&session).Update("locked_at", now)
+			}
+			if session.LockedAt != nil {
+				if !lockedSessionAllowedPaths[c.FullPath()] {
+					c.JSON(http.StatusLocked, gin.H{"error": "Session is locked, enter your PIN to continue", "code": "SESSION_LOCKED"})
+					c.Abort()
+					return
+				}
+			} else if now.Sub(session.LastUsedAt) > sessionTouchInterval {
+				config.DB.Model(&session).Update("last_used_at", now)
+			}
+			c.Set("terminalID", *session.TerminalID)
+		}
+
+		// Wajib ganti password dulu sebelum memakai endpoint lain (kecuali logout)
+		if user.MustChangePassword && !passwordChangeAllowedPaths[c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Password change required"})
+			c.Abort()
+			return
+		}
+
+		// Role diambil dari database, perubahan role berlaku tanpa login ulang
+		roleService := services.NewRoleService()
+		permissions, err := roleService.Permissions(user.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		// Role yang mewajibkan 2FA harus mengaktifkannya dulu (PIN di terminal sudah
+		// dihitung sebagai faktor kedua)
+		if !user.TOTPEnabled && session.TerminalID == nil && !twoFactorSetupAllowedPaths[c.FullPath()] {
+			required, err := roleService.RequiresTwoFactor(user.Role)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if required {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error": "Two-factor authentication required, set it up at /me/2fa/setup",
+					"code":  "TWO_FACTOR_SETUP_REQUIRED",
+				})
+				c.Abort()
+				return
+			}
+		}
+
 		// simpan info user di context
 		c.Set("userID", claims.UserID)
-		c.Set("role", claims.Role)
+		c.Set("role", user.Role)
+		c.Set("permissions", permissions)
+		c.Set("sessionID", claims.SessionID)
 
 		c.Next()
 	}
 }
 
-// RoleMiddleware untuk role-based access (admin/cashier)
-func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
+// authenticateAPIKey meneruskan request yang memakai API key aktif. Request ini
+// tidak punya user, permission-nya adalah permission milik key.
+func authenticateAPIKey(c *gin.Context, key string) {
+	apiKey, err := services.NewAPIKeyService().Authenticate(key, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	// Scope key hanya diperiksa PermissionMiddleware, endpoint tanpa permission ditolak
+	if !routeDeclaresPermission(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot use this endpoint"})
+		c.Abort()
+		return
+	}
+
+	role := services.APIKeyRole(apiKey.ID)
+	permissions, err := services.NewRoleService().Permissions(role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
+
+	c.Set("role", role)
+	c.Set("permissions", permissions)
+	c.Set("apiKeyID", apiKey.ID)
+
+	c.Next()
+}
+
+// Nama handler PermissionMiddleware di rantai handler sebuah route
+var permissionHandlerName = runtime.FuncForPC(reflect.ValueOf(PermissionMiddleware()).Pointer()).Name()
+
+// routeDeclaresPermission memeriksa apakah route yang dituju memakai PermissionMiddleware
+func routeDeclaresPermission(c *gin.Context) bool {
+	for _, name := range c.HandlerNames() {
+		if name == permissionHandlerName {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionMiddleware hanya meneruskan request jika role user punya semua permission
+func PermissionMiddleware(required ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Allow OPTIONS requests to pass through
 		if c.Request.Method == "OPTIONS" {
@@ -50,20 +216,13 @@ func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 			return
 		}
 
-		role, exists := c.Get("role")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Role not found"})
-			c.Abort()
-			return
-		}
-		userRole := role.(string)
-		for _, r := range allowedRoles {
-			if userRole == r {
-				c.Next()
+		for _, name := range required {
+			if !common.HasPermission(c, name) {
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Access denied, permission '%s' required", name)})
+				c.Abort()
 				return
 			}
 		}
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		c.Abort()
+		c.Next()
 	}
-}
\ No newline at end of file
+}
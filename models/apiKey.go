@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// APIKey lets an integration call the API without a user account. The key is shown
+// once when created and only its SHA-256 hash is stored; Prefix identifies it in lists.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);not null" json:"prefix"`
+	KeyHash    string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"type:varchar(45);index" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	CreatedBy  *uint      `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Permissions []APIKeyPermission `gorm:"foreignKey:APIKeyID" json:"permissions"`
+}
+
+type APIKeyPermission struct {
+	ID         uint   `gorm:"primaryKey" json:"-"`
+	APIKeyID   uint   `gorm:"not null;uniqueIndex:unique_api_key_permission" json:"-"`
+	Permission string `gorm:"type:varchar(100);not null;uniqueIndex:unique_api_key_permission" json:"permission"`
+}
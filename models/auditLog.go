@@ -6,12 +6,14 @@ type AuditLog struct {
     ID          uint      `gorm:"primaryKey" json:"id"`
     EntityType  string    `gorm:"type:varchar(50);not null;index" json:"entity_type"` // "item", "transaction", "user", etc.
     EntityID    uint      `gorm:"not null;index" json:"entity_id"`
-    Action      string    `gorm:"type:enum('create','update','delete','status_change');not null" json:"action"`
+    Action      string    `gorm:"type:enum('create','update','delete','status_change','restore','purge','refund','login','logout','open','close');not null" json:"action"`
     UserID      *uint     `gorm:"index" json:"user_id,omitempty"` // Who made the change
     OldValue    *string   `gorm:"type:json" json:"old_value,omitempty"` // JSON of old state
     NewValue    *string   `gorm:"type:json" json:"new_value,omitempty"` // JSON of new state
     Changes     *string   `gorm:"type:json" json:"changes,omitempty"` // Specific fields changed
     IPAddress   *string   `gorm:"type:varchar(45)" json:"ip_address,omitempty"`
+    TerminalID  *uint     `gorm:"index" json:"terminal_id,omitempty"` // Set for changes made from a PIN session
+    APIKeyID    *uint     `gorm:"index" json:"api_key_id,omitempty"` // Set for changes made with an API key
     Description string    `gorm:"type:text" json:"description"` // Human-readable description
     CreatedAt   time.Time `gorm:"autoCreateTime;index" json:"created_at"`
     
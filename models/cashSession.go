@@ -7,13 +7,15 @@ type CashSession struct {
 
 	UserID uint `gorm:"not null"`
 
+	LocationID *uint // Selling location for transactions made during the session
+
 	OpeningCash float64 `gorm:"not null"`
 
 	TotalCashIn     float64 `gorm:"default:0"`
 	TotalChange     float64 `gorm:"default:0"`
 	TotalRefundCash float64 `gorm:"default:0"`
 
-	ExpectedCash float64  `gorm:"default:0"`
+	ExpectedCash float64 `gorm:"default:0"`
 	ClosingCash  *float64
 	Difference   *float64
 
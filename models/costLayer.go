@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// CostLayer is a quantity of an item received at one unit cost. Sales consume
+// layers oldest first, which gives the FIFO cost of goods sold.
+type CostLayer struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ItemID      uint      `gorm:"not null;index" json:"item_id"`
+	Quantity    int       `gorm:"not null" json:"quantity"`  // Received
+	Remaining   int       `gorm:"not null" json:"remaining"` // Not yet sold
+	UnitCost    float64   `gorm:"not null" json:"unit_cost"`
+	ReferenceID string    `gorm:"type:varchar(50)" json:"reference_id,omitempty"`
+	CreatedAt   time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// DataMigration records a one-time data fix that has been applied, so it does not
+// run again on the next start.
+type DataMigration struct {
+	Name      string    `gorm:"type:varchar(100);primaryKey" json:"name"`
+	AppliedAt time.Time `gorm:"autoCreateTime" json:"applied_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// GoodsReceipt records stock coming in from a supplier at a purchase cost.
+type GoodsReceipt struct {
+	ID          uint               `gorm:"primaryKey" json:"id"`
+	LocationID  uint               `gorm:"not null;index" json:"location_id"`
+	ReferenceNo *string            `gorm:"type:varchar(100)" json:"reference_no,omitempty"` // supplier invoice / delivery note
+	Note        *string            `gorm:"type:text" json:"note,omitempty"`
+	ReceivedBy  *uint              `gorm:"index" json:"received_by,omitempty"`
+	Items       []GoodsReceiptItem `json:"items"`
+	CreatedAt   time.Time          `gorm:"autoCreateTime;index" json:"created_at"`
+
+	// Relations
+	Location Location `gorm:"foreignKey:LocationID" json:"location"`
+}
+
+type GoodsReceiptItem struct {
+	ID             uint    `gorm:"primaryKey" json:"id"`
+	GoodsReceiptID uint    `gorm:"not null;index" json:"goods_receipt_id"`
+	ItemID         uint    `gorm:"not null;index" json:"item_id"`
+	Quantity       int     `gorm:"not null" json:"quantity"`
+	UnitCost       float64 `gorm:"not null" json:"unit_cost"`
+	LotID          *uint   `json:"lot_id,omitempty"`
+
+	Item Item `gorm:"foreignKey:ItemID" json:"item"`
+	Lot  *Lot `gorm:"foreignKey:LotID" json:"lot,omitempty"`
+}
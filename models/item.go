@@ -9,12 +9,46 @@ import (
 type Item struct {
 	ID          uint           `gorm:"primaryKey" json:"id"`
 	Name        string         `gorm:"unique;type:varchar(100);not null" json:"name"`
+	SKU         *string        `gorm:"type:varchar(64);uniqueIndex" json:"sku,omitempty"`
+	Barcode     *string        `gorm:"type:varchar(64);uniqueIndex" json:"barcode,omitempty"`
 	Description *string        `gorm:"type:text" json:"description,omitempty"`
+	Category    *string        `gorm:"type:varchar(100);index" json:"category,omitempty"`
 	Stock       int            `gorm:"not null;default:0" json:"stock"`
 	BuyPrice    float64        `gorm:"not null" json:"buy_price"`
 	Price       float64        `gorm:"not null" json:"price"`
+	AverageCost float64        `gorm:"not null;default:0" json:"average_cost"` // Weighted-average unit cost, updated on goods receipt
 	ImageURL    *string        `gorm:"type:varchar(255)" json:"image_url,omitempty" nullable:"true"`
+
+	// Uploaded image, ImageURL then points at the public image endpoint
+	ThumbnailURL *string `gorm:"type:varchar(255)" json:"thumbnail_url,omitempty"`
+	ImageKey     *string `gorm:"type:varchar(255)" json:"-"`
+	ThumbnailKey *string `gorm:"type:varchar(255)" json:"-"`
+
+	// Replenishment
+	SupplierID   *uint `gorm:"index" json:"supplier_id,omitempty"`
+	MinStock     int   `gorm:"not null;default:0" json:"min_stock"`     // Safety stock
+	ReorderPoint int   `gorm:"not null;default:0" json:"reorder_point"` // Reorder when stock falls to this level, 0 = derive from sales
+	ReorderQty   int   `gorm:"not null;default:0" json:"reorder_qty"`   // Preferred order quantity, 0 = derive from sales
+
+	// Serial tracking (power tools, pumps)
+	IsSerialized   bool `gorm:"not null;default:false" json:"is_serialized"`
+	WarrantyMonths int  `gorm:"not null;default:0" json:"warranty_months"`
+
+	// Kits hold no stock of their own, selling one deducts its components
+	IsKit bool `gorm:"not null;default:false" json:"is_kit"`
+
     CreatedAt   time.Time         `gorm:"autoCreateTime" json:"created_at"`
     UpdatedAt   time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
     DeletedAt   gorm.DeletedAt    `gorm:"index" json:"-"`
+
+	Supplier *Supplier `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+
+	// Per-location breakdown of Stock
+	StockLevels []ItemStock `gorm:"foreignKey:ItemID" json:"stock_levels,omitempty"`
+
+	Components []KitComponent `gorm:"foreignKey:KitID" json:"components,omitempty"`
+
+	// Computed on read: stock held by draft reservations and what is left to sell
+	Reserved  int `gorm:"-" json:"reserved"`
+	Available int `gorm:"-" json:"available"`
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ItemStock holds the on-hand quantity of an item at one location.
+// Item.Stock is kept as the sum over all locations.
+type ItemStock struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ItemID     uint      `gorm:"not null;uniqueIndex:unique_item_location" json:"item_id"`
+	LocationID uint      `gorm:"not null;uniqueIndex:unique_item_location" json:"location_id"`
+	Quantity   int       `gorm:"not null;default:0" json:"quantity"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Location *Location `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// KitComponent is one item that goes into a kit, per kit sold.
+type KitComponent struct {
+	ID          uint `gorm:"primaryKey" json:"id"`
+	KitID       uint `gorm:"not null;uniqueIndex:unique_kit_component" json:"kit_id"`
+	ComponentID uint `gorm:"not null;uniqueIndex:unique_kit_component;index" json:"component_id"`
+	Quantity    int  `gorm:"not null" json:"quantity"`
+
+	Component Item `gorm:"foreignKey:ComponentID" json:"component"`
+}
+
+// TransactionItemComponent is a component taken from stock when a kit was sold,
+// so a refund returns what left the shelf even if the kit has changed since.
+type TransactionItemComponent struct {
+	ID                uint    `gorm:"primaryKey" json:"id"`
+	TransactionItemID uint    `gorm:"not null;index" json:"transaction_item_id"`
+	ItemID            uint    `gorm:"not null;index" json:"item_id"`
+	Quantity          int     `gorm:"not null" json:"quantity"`
+	UnitCost          float64 `gorm:"not null;default:0" json:"unit_cost"`
+
+	Item Item `gorm:"foreignKey:ItemID" json:"item"`
+}
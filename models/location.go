@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Location struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"unique;type:varchar(100);not null" json:"name"`
+	Code      string         `gorm:"unique;type:varchar(20);not null" json:"code"` // e.g. "TOKO", "GUDANG"
+	IsDefault bool           `gorm:"not null;default:false" json:"is_default"`     // Used when no location is given
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
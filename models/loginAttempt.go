@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// LoginAttempt records every login, successful or not. Failures drive the per-user
+// and per-IP lockout.
+type LoginAttempt struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Username   string    `gorm:"type:varchar(100);not null;index:idx_login_attempt_username" json:"username"` // as typed, lower-cased
+	UserID     *uint     `gorm:"index" json:"user_id,omitempty"`                                              // set when the username exists
+	IPAddress  string    `gorm:"type:varchar(45);not null;index:idx_login_attempt_ip" json:"ip_address"`
+	UserAgent  string    `gorm:"type:varchar(255)" json:"user_agent"`
+	TerminalID *uint     `gorm:"index" json:"terminal_id,omitempty"` // PIN logins
+	Success    bool      `gorm:"not null" json:"success"`
+	Reason     string    `gorm:"type:enum('ok','unknown_user','wrong_password','wrong_pin','wrong_2fa','disabled','locked');not null" json:"reason"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Lot is a production batch of an item received in one goods receipt.
+type Lot struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	ItemID         uint       `gorm:"not null;index" json:"item_id"`
+	BatchNumber    string     `gorm:"type:varchar(100);not null;index" json:"batch_number"`
+	ExpiryDate     *time.Time `gorm:"type:date;index" json:"expiry_date,omitempty"`
+	Quantity       int        `gorm:"not null" json:"quantity"`          // Received
+	Remaining      int        `gorm:"not null" json:"remaining"`         // Still in stock
+	LocationID     uint       `gorm:"not null;index" json:"location_id"` // Where the batch is stocked
+	GoodsReceiptID *uint      `gorm:"index" json:"goods_receipt_id,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+
+	Item Item `gorm:"foreignKey:ItemID" json:"item"`
+}
+
+// TransactionItemLot records which lot a sold quantity was taken from.
+type TransactionItemLot struct {
+	ID                uint `gorm:"primaryKey" json:"id"`
+	TransactionItemID uint `gorm:"not null;index" json:"transaction_item_id"`
+	LotID             uint `gorm:"not null;index" json:"lot_id"`
+	Quantity          int  `gorm:"not null" json:"quantity"`
+
+	Lot *Lot `gorm:"foreignKey:LotID" json:"lot,omitempty"`
+}
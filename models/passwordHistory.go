@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// PasswordHistory keeps previous password hashes so they cannot be reused.
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Hash      string    `gorm:"type:varchar(255);not null" json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// PriceHistory records one change of an item's selling or buy price.
+type PriceHistory struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	ItemID            uint      `gorm:"not null;index:idx_price_history_item_date" json:"item_id"`
+	OldPrice          float64   `gorm:"not null;default:0" json:"old_price"`
+	NewPrice          float64   `gorm:"not null" json:"new_price"`
+	OldBuyPrice       float64   `gorm:"not null;default:0" json:"old_buy_price"`
+	NewBuyPrice       float64   `gorm:"not null" json:"new_buy_price"`
+	Source            string    `gorm:"type:enum('create','manual','scheduled','import','initial');not null" json:"source"`
+	ScheduledChangeID *uint     `gorm:"index" json:"scheduled_change_id,omitempty"`
+	ChangedBy         *uint     `json:"changed_by,omitempty"`
+	CreatedAt         time.Time `gorm:"autoCreateTime;index:idx_price_history_item_date" json:"created_at"`
+}
+
+// ScheduledPriceChange is a price change that takes effect at EffectiveAt.
+// Nil prices are left unchanged.
+type ScheduledPriceChange struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ItemID      uint       `gorm:"not null;index" json:"item_id"`
+	Price       *float64   `json:"price,omitempty"`
+	BuyPrice    *float64   `json:"buy_price,omitempty"`
+	EffectiveAt time.Time  `gorm:"not null;index" json:"effective_at"`
+	Status      string     `gorm:"type:enum('pending','applied','cancelled');default:'pending';index" json:"status"`
+	Note        *string    `gorm:"type:text" json:"note,omitempty"`
+	CreatedBy   *uint      `json:"created_by,omitempty"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Item Item `gorm:"foreignKey:ItemID" json:"item"`
+}
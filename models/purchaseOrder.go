@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type PurchaseOrder struct {
+	ID         uint                `gorm:"primaryKey" json:"id"`
+	SupplierID *uint               `gorm:"index" json:"supplier_id,omitempty"`
+	Status     string              `gorm:"type:enum('draft','ordered','received','cancelled');default:'draft'" json:"status"`
+	Note       *string             `gorm:"type:text" json:"note,omitempty"`
+	CreatedBy  *uint               `gorm:"index" json:"created_by,omitempty"`
+	Items      []PurchaseOrderItem `json:"items"`
+	CreatedAt  time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Supplier *Supplier `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+}
+
+type PurchaseOrderItem struct {
+	ID              uint    `gorm:"primaryKey" json:"id"`
+	PurchaseOrderID uint    `gorm:"not null;index" json:"purchase_order_id"`
+	ItemID          uint    `gorm:"not null;index" json:"item_id"`
+	Quantity        int     `gorm:"not null" json:"quantity"`
+	UnitCost        float64 `gorm:"not null;default:0" json:"unit_cost"` // Estimate, from the item's average cost
+
+	Item Item `gorm:"foreignKey:ItemID" json:"item"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Role is a named set of permissions assigned to users. System roles (admin,
+// cashier) cannot be deleted, and admin always holds every permission.
+type Role struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"type:varchar(50);uniqueIndex;not null" json:"name"`
+	Description string    `gorm:"type:varchar(255)" json:"description"`
+	IsSystem    bool      `gorm:"not null;default:false" json:"is_system"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Users with this role must set up two-factor authentication before doing anything else
+	RequireTwoFactor bool `gorm:"not null;default:false" json:"require_two_factor"`
+
+	Permissions []RolePermission `gorm:"foreignKey:RoleID" json:"permissions"`
+}
+
+type RolePermission struct {
+	ID         uint   `gorm:"primaryKey" json:"-"`
+	RoleID     uint   `gorm:"not null;uniqueIndex:unique_role_permission" json:"-"`
+	Permission string `gorm:"type:varchar(100);not null;uniqueIndex:unique_role_permission" json:"permission"`
+}
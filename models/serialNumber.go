@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// SerialNumber is one physical unit of a serialized item.
+type SerialNumber struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	ItemID            uint       `gorm:"not null;uniqueIndex:unique_item_serial" json:"item_id"`
+	Serial            string     `gorm:"type:varchar(100);not null;uniqueIndex:unique_item_serial;index" json:"serial"`
+	Status            string     `gorm:"type:enum('in_stock','sold');default:'in_stock';index" json:"status"`
+	GoodsReceiptID    *uint      `gorm:"index" json:"goods_receipt_id,omitempty"`
+	TransactionItemID *uint      `gorm:"index" json:"transaction_item_id,omitempty"` // Draft or sale the unit is assigned to
+	SoldAt            *time.Time `json:"sold_at,omitempty"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Item Item `gorm:"foreignKey:ItemID" json:"item"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Session is one login. The refresh token itself is never stored, only its SHA-256
+// hash; it is replaced on every refresh and the previous hash is kept to detect a
+// stolen token being replayed.
+type Session struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	UserID              uint       `gorm:"not null;index" json:"user_id"`
+	RefreshTokenHash    string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	PreviousRefreshHash *string    `gorm:"type:char(64);index" json:"-"`
+	ExpiresAt           time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt          time.Time  `json:"last_used_at"`
+	RevokedAt           *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	IPAddress           string     `gorm:"type:varchar(45)" json:"ip_address"`
+	UserAgent           string     `gorm:"type:varchar(255)" json:"user_agent"`
+
+	// PIN sessions on a registered terminal, locked after a period of inactivity
+	TerminalID *uint      `gorm:"index" json:"terminal_id,omitempty"`
+	LockedAt   *time.Time `json:"locked_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// StockReservation holds stock for a draft transaction until it is completed,
+// deleted or the reservation expires.
+type StockReservation struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	TransactionID uint      `gorm:"not null;index" json:"transaction_id"`
+	ItemID        uint      `gorm:"not null;index:idx_reservation_item_status" json:"item_id"`
+	LocationID    uint      `gorm:"not null" json:"location_id"`
+	Quantity      int       `gorm:"not null" json:"quantity"`
+	Status        string    `gorm:"type:enum('active','released','consumed','expired');default:'active';index:idx_reservation_item_status" json:"status"`
+	ExpiresAt     time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
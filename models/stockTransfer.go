@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type StockTransfer struct {
+	ID             uint                `gorm:"primaryKey" json:"id"`
+	FromLocationID uint                `gorm:"not null;index" json:"from_location_id"`
+	ToLocationID   uint                `gorm:"not null;index" json:"to_location_id"`
+	Status         string              `gorm:"type:enum('in_transit','received','cancelled');default:'in_transit'" json:"status"`
+	Note           *string             `gorm:"type:text" json:"note,omitempty"`
+	CreatedBy      *uint               `gorm:"index" json:"created_by,omitempty"`
+	ReceivedBy     *uint               `json:"received_by,omitempty"`
+	SentAt         time.Time           `json:"sent_at"`
+	ReceivedAt     *time.Time          `json:"received_at,omitempty"`
+	Items          []StockTransferItem `json:"items"`
+	CreatedAt      time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+
+	// Relations
+	FromLocation Location `gorm:"foreignKey:FromLocationID" json:"from_location"`
+	ToLocation   Location `gorm:"foreignKey:ToLocationID" json:"to_location"`
+}
+
+type StockTransferItem struct {
+	ID              uint `gorm:"primaryKey" json:"id"`
+	StockTransferID uint `gorm:"not null;index" json:"stock_transfer_id"`
+	ItemID          uint `gorm:"not null" json:"item_id"`
+	Quantity        int  `gorm:"not null" json:"quantity"`
+
+	Item Item                   `gorm:"foreignKey:ItemID" json:"item"`
+	Lots []StockTransferItemLot `gorm:"foreignKey:StockTransferItemID" json:"lots,omitempty"`
+}
+
+// StockTransferItemLot records which lot at the source a transferred quantity was
+// taken from, so it lands in the same batch at the destination.
+type StockTransferItemLot struct {
+	ID                  uint `gorm:"primaryKey" json:"id"`
+	StockTransferItemID uint `gorm:"not null;index" json:"stock_transfer_item_id"`
+	LotID               uint `gorm:"not null;index" json:"lot_id"`
+	Quantity            int  `gorm:"not null" json:"quantity"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Supplier struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	Name         string         `gorm:"unique;type:varchar(100);not null" json:"name"`
+	Phone        *string        `gorm:"type:varchar(30)" json:"phone,omitempty"`
+	Email        *string        `gorm:"type:varchar(100)" json:"email,omitempty"`
+	LeadTimeDays int            `gorm:"not null;default:7" json:"lead_time_days"` // Days from order to delivery
+	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
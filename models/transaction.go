@@ -17,6 +17,10 @@ type Transaction struct {
     Items       []TransactionItem `json:"items"`
     Note        *string           `gorm:"type:text" json:"note,omitempty"`
     TransactionType string        `gorm:"type:enum('onsite','deliver');default:'onsite'" json:"transaction_type"`
+    LocationID  *uint             `gorm:"index" json:"location_id,omitempty"` // Selling location
+    TerminalID  *uint             `gorm:"index" json:"terminal_id,omitempty"` // POS terminal of a PIN session
+    CustomerName  *string         `gorm:"type:varchar(100)" json:"customer_name,omitempty"`
+    CustomerPhone *string         `gorm:"type:varchar(30)" json:"customer_phone,omitempty"`
 
 
     CreatedAt   time.Time         `gorm:"autoCreateTime" json:"created_at"`
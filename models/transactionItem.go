@@ -7,7 +7,10 @@ type TransactionItem struct {
 	Quantity      int     `gorm:"not null;default:1" json:"quantity"`
 	Price         float64 `gorm:"not null" json:"price"`
 	Subtotal      float64 `gorm:"not null" json:"subtotal"`
+	UnitCost      float64 `gorm:"not null;default:0" json:"unit_cost"` // Cost of goods sold per unit, snapshot at completion
 
 	// Relasi
-	Item Item `gorm:"foreignKey:ItemID" json:"item"`
+	Item    Item                 `gorm:"foreignKey:ItemID" json:"item"`
+	Lots    []TransactionItemLot `gorm:"foreignKey:TransactionItemID" json:"lots,omitempty"`
+	Serials []SerialNumber       `gorm:"foreignKey:TransactionItemID" json:"serials,omitempty"`
 }
\ No newline at end of file
@@ -1,8 +1,35 @@
 package models
 
+import "time"
+
 type User struct {
 	ID       uint   `json:"id"`
 	Username string `json:"username"`
-	Password string `json:"password"`
-	Role     string `json:"role" gorm:"type:enum('admin','cashier');default:'cashier'"`
+	Password string `json:"-"`
+	Role     string `json:"role" gorm:"type:varchar(50);not null;default:'cashier';index"` // name of a Role
+
+	// Disabled accounts cannot log in and their tokens stop working
+	IsActive   bool       `gorm:"not null;default:true" json:"is_active"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+
+	// Set for new or reset accounts when the policy forces users to pick their own password
+	MustChangePassword bool       `gorm:"not null;default:false" json:"must_change_password"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
+
+	// Numeric PIN for quick login on a registered terminal, bcrypt hashed
+	PinHash  *string    `gorm:"type:varchar(60)" json:"-"`
+	PinSetAt *time.Time `json:"pin_set_at,omitempty"`
+
+	// TOTP two-factor authentication. The secret is stored at setup and only used
+	// once confirmed with a code; TOTPLastStep stops a code being used twice.
+	TOTPSecret    *string    `gorm:"column:totp_secret;type:varchar(64)" json:"-"`
+	TOTPEnabled   bool       `gorm:"column:totp_enabled;not null;default:false" json:"totp_enabled"`
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at" json:"totp_enabled_at,omitempty"`
+	TOTPLastStep  uint64     `gorm:"column:totp_last_step;not null;default:0" json:"-"`
+
+	// Bumped on a password change, tokens carrying an older version are rejected
+	TokenVersion uint `gorm:"not null;default:0" json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -3,6 +3,7 @@ package routes
 import (
 	"kd-api/controllers"
 	"kd-api/middlewares"
+	"kd-api/utils/permission"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,17 +11,72 @@ import (
 func RegisterRoutes(r *gin.Engine) {
 
 	r.POST("/login", controllers.Login)
+	r.POST("/auth/refresh", controllers.RefreshToken)
+	r.POST("/auth/pin-login", controllers.PinLogin)
+	r.POST("/auth/2fa/verify", controllers.VerifyTwoFactor)
+
+	auth := r.Group("/auth")
+	auth.Use(middlewares.AuthMiddleware())
+	{
+		auth.POST("/logout", controllers.Logout)
+		auth.POST("/logout-all", controllers.LogoutAll)
+		auth.POST("/unlock", controllers.UnlockSession)
+	}
 
 	// Public items buat landing page
 	r.GET("/public/items", controllers.GetItems)
 	r.GET("/public/items/search", controllers.GetItemsByName)
 	r.GET("/public/items/:id", controllers.GetItemByID)
+	r.GET("/public/items/:id/image", controllers.GetItemImage)
+	r.GET("/public/items/:id/image/thumbnail", controllers.GetItemThumbnail)
 
 	// Inventory
 	inventory := r.Group("/inventory")
 	inventory.Use(middlewares.AuthMiddleware())
 	{
 		inventory.GET("/history", controllers.GetInventoryHistory)
+		inventory.GET("/reconcile", middlewares.PermissionMiddleware(permission.InventoryReconcile), controllers.GetInventoryReconciliation)
+		inventory.POST("/reconcile", middlewares.PermissionMiddleware(permission.InventoryReconcile), controllers.ReconcileInventory)
+
+		inventory.GET("/transfers", controllers.GetStockTransfers)
+		inventory.GET("/transfers/:id", controllers.GetStockTransferByID)
+		inventory.POST("/transfers", middlewares.PermissionMiddleware(permission.InventoryTransfer), controllers.CreateStockTransfer)
+		inventory.POST("/transfers/:id/receive", middlewares.PermissionMiddleware(permission.InventoryTransfer), controllers.ReceiveStockTransfer)
+		inventory.POST("/transfers/:id/cancel", middlewares.PermissionMiddleware(permission.InventoryTransfer), controllers.CancelStockTransfer)
+
+		// Goods receipts carry purchase costs
+		inventory.GET("/receipts", middlewares.PermissionMiddleware(permission.InventoryReceive), controllers.GetGoodsReceipts)
+		inventory.GET("/receipts/:id", middlewares.PermissionMiddleware(permission.InventoryReceive), controllers.GetGoodsReceiptByID)
+		inventory.POST("/receipts", middlewares.PermissionMiddleware(permission.InventoryReceive), controllers.CreateGoodsReceipt)
+
+		inventory.GET("/lots", controllers.GetLots)
+		inventory.GET("/lots/expiring", controllers.GetExpiringLots)
+		inventory.GET("/lots/:id/transactions", controllers.GetLotTransactions)
+
+		inventory.GET("/serials/:serial", controllers.LookupSerial)
+
+		inventory.GET("/reorder-suggestions", middlewares.PermissionMiddleware(permission.InventoryReorder), controllers.GetReorderSuggestions)
+		inventory.POST("/reorder-suggestions/purchase-orders", middlewares.PermissionMiddleware(permission.InventoryReorder), controllers.CreatePurchaseOrdersFromSuggestions)
+		inventory.GET("/purchase-orders", middlewares.PermissionMiddleware(permission.InventoryReorder), controllers.GetPurchaseOrders)
+		inventory.GET("/purchase-orders/:id", middlewares.PermissionMiddleware(permission.InventoryReorder), controllers.GetPurchaseOrderByID)
+	}
+
+	// Locations
+	locations := r.Group("/locations")
+	locations.Use(middlewares.AuthMiddleware())
+	{
+		locations.GET("/", controllers.GetLocations)
+		locations.POST("/", middlewares.PermissionMiddleware(permission.LocationsManage), controllers.CreateLocation)
+		locations.PUT("/:id", middlewares.PermissionMiddleware(permission.LocationsManage), controllers.UpdateLocation)
+	}
+
+	// Suppliers
+	suppliers := r.Group("/suppliers")
+	suppliers.Use(middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permission.SuppliersManage))
+	{
+		suppliers.GET("/", controllers.GetSuppliers)
+		suppliers.POST("/", controllers.CreateSupplier)
+		suppliers.PUT("/:id", controllers.UpdateSupplier)
 	}
 
 	// Items 
@@ -29,40 +85,53 @@ func RegisterRoutes(r *gin.Engine) {
 	{
 		items.GET("/", controllers.GetItems)
 		items.GET("/search", controllers.GetItemsByName)
+		items.GET("/autocomplete", controllers.AutocompleteItems)
+		items.GET("/deleted", middlewares.PermissionMiddleware(permission.ItemsDelete), controllers.GetDeletedItems)
 		items.GET("/:id", controllers.GetItemByID)     
-		items.POST("/", middlewares.RoleMiddleware("admin", "cashier"), controllers.CreateItem)
-		items.PUT("/:id", middlewares.RoleMiddleware("admin", "cashier"), controllers.UpdateItem)
-		items.DELETE("/:id", middlewares.RoleMiddleware("admin", "cashier"), controllers.DeleteItem)
-		items.POST("/bulk", middlewares.RoleMiddleware("admin", "cashier"), controllers.BulkCreateItems)
-		items.GET("/export/csv", middlewares.RoleMiddleware("admin", "cashier"), controllers.ExportItems)
+		items.POST("/", middlewares.PermissionMiddleware(permission.ItemsCreate), controllers.CreateItem)
+		items.PUT("/:id", middlewares.PermissionMiddleware(permission.ItemsUpdate), controllers.UpdateItem)
+		items.DELETE("/:id", middlewares.PermissionMiddleware(permission.ItemsDelete), controllers.DeleteItem)
+		items.POST("/:id/restore", middlewares.PermissionMiddleware(permission.ItemsDelete), controllers.RestoreItem)
+		items.DELETE("/:id/purge", middlewares.PermissionMiddleware(permission.ItemsPurge), controllers.PurgeItem)
+		items.POST("/bulk", middlewares.PermissionMiddleware(permission.ItemsCreate), controllers.BulkCreateItems)
+		items.POST("/import", middlewares.PermissionMiddleware(permission.ItemsImport), controllers.ImportItems)
+		items.GET("/export", middlewares.PermissionMiddleware(permission.ItemsExport), controllers.ExportItems)
+		items.GET("/export/csv", middlewares.PermissionMiddleware(permission.ItemsExport), controllers.ExportItems)
+
+		items.POST("/:id/image", middlewares.PermissionMiddleware(permission.ItemsUpdate), controllers.UploadItemImage)
+
+		items.GET("/:id/price-history", controllers.GetItemPriceHistory)
+		items.POST("/:id/price-schedule", middlewares.PermissionMiddleware(permission.ItemsManagePrices), controllers.ScheduleItemPriceChange)
+		items.DELETE("/:id/price-schedule/:scheduleId", middlewares.PermissionMiddleware(permission.ItemsManagePrices), controllers.CancelItemPriceChange)
 	}
 
 	// Transactions
 	transactions := r.Group("/transactions")
 	transactions.Use(middlewares.AuthMiddleware())
 	{
-		transactions.POST("/", controllers.CreateTransaction)
+		transactions.POST("/", middlewares.PermissionMiddleware(permission.TransactionsCreate), controllers.CreateTransaction)
 		transactions.GET("/", controllers.GetTransactions)
 		transactions.GET("/history", controllers.GetTransactionHistory)
 		transactions.GET("/:id", controllers.GetTransactionByID)
-		transactions.PATCH("/:id", controllers.UpdateTransactionStatus)
+		// Each status change also checks its own permission
+		transactions.PATCH("/:id", middlewares.PermissionMiddleware(permission.TransactionsCreate), controllers.UpdateTransactionStatus)
 		transactions.GET("/history/by-date", controllers.GetTransactionHistoryByDate)
 
-		transactions.POST("/:id/refund", controllers.RefundTransaction)
+		transactions.POST("/:id/refund", middlewares.PermissionMiddleware(permission.TransactionsRefund), controllers.RefundTransaction)
 		transactions.GET("/drafts", controllers.GetDraftTransactions)
-		transactions.DELETE("/:id", controllers.DeleteTransaction)
+		transactions.DELETE("/:id", middlewares.PermissionMiddleware(permission.TransactionsDelete), controllers.DeleteTransaction)
 	}
 
 	// Dashboard
 	dashboard := r.Group("/dashboard")
-	dashboard.Use(middlewares.AuthMiddleware())
+	dashboard.Use(middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permission.ReportsView))
 	{
 		dashboard.GET("/", controllers.GetDashboard)
 	}
 
-	// Attendance (admin only)
+	// Attendance
 	attendance := r.Group("/attendance")
-	attendance.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("admin"))
+	attendance.Use(middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permission.AttendanceManage))
 	{
 		attendance.GET("/", controllers.GetAttendances)
 		attendance.POST("/", controllers.CreateAttendance)
@@ -70,16 +139,68 @@ func RegisterRoutes(r *gin.Engine) {
 		attendance.GET("/history", controllers.GetAttendanceHistory)
 	}
 
-	// Users (admin only)
+	// Own account
+	me := r.Group("/me")
+	me.Use(middlewares.AuthMiddleware())
+	{
+		me.POST("/password", controllers.ChangeOwnPassword)
+		me.POST("/pin", controllers.SetOwnPin)
+		me.POST("/2fa/setup", controllers.SetupTwoFactor)
+		me.POST("/2fa/enable", controllers.EnableTwoFactor)
+		me.POST("/2fa/disable", controllers.DisableTwoFactor)
+		me.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
+	}
+
+	// Users
 	users := r.Group("/users")
-	users.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("admin"))
+	users.Use(middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permission.UsersManage))
 	{
 		users.GET("/", controllers.GetUsers)
+		users.GET("/login-attempts", controllers.GetLoginAttempts)
+		users.POST("/", controllers.CreateUser)
+		users.PUT("/:id", controllers.UpdateUser)
+		users.POST("/:id/disable", controllers.DisableUser)
+		users.POST("/:id/enable", controllers.EnableUser)
+		users.POST("/:id/reset-password", controllers.ResetUserPassword)
+		users.GET("/:id/sessions", controllers.GetUserSessions)
+		users.POST("/:id/kill-sessions", controllers.KillUserSessions)
+		users.DELETE("/:id/pin", controllers.ClearUserPin)
+		users.DELETE("/:id/2fa", controllers.ResetUserTwoFactor)
+	}
+
+	// POS terminals for PIN login
+	terminals := r.Group("/terminals")
+	terminals.Use(middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permission.TerminalsManage))
+	{
+		terminals.GET("/", controllers.GetTerminals)
+		terminals.POST("/", controllers.RegisterTerminal)
+		terminals.POST("/:id/revoke", controllers.RevokeTerminal)
+	}
+
+	// API keys for integrations, sent in the X-API-Key header
+	apiKeys := r.Group("/api-keys")
+	apiKeys.Use(middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permission.APIKeysManage))
+	{
+		apiKeys.GET("/", controllers.GetAPIKeys)
+		apiKeys.POST("/", controllers.CreateAPIKey)
+		apiKeys.POST("/:id/revoke", controllers.RevokeAPIKey)
+	}
+
+	// Roles and the permissions they can be given
+	roles := r.Group("/roles")
+	roles.Use(middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permission.RolesManage))
+	{
+		roles.GET("/", controllers.GetRoles)
+		roles.GET("/:id", controllers.GetRoleByID)
+		roles.POST("/", controllers.CreateRole)
+		roles.PUT("/:id", controllers.UpdateRole)
+		roles.DELETE("/:id", controllers.DeleteRole)
 	}
+	r.GET("/permissions", middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permission.RolesManage), controllers.GetPermissions)
 
-	// Audit logs (admin only)
+	// Audit logs
 	audit := r.Group("/audit-logs")
-	audit.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("admin"))
+	audit.Use(middlewares.AuthMiddleware(), middlewares.PermissionMiddleware(permission.AuditView))
 	{
 		audit.GET("/", controllers.GetAuditLogs)
 	}
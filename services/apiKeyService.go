@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Requests made with an API key act under the role "apikey:<id>", which has the
+// key's permissions. Role names cannot start with it.
+const apiKeyRolePrefix = "apikey:"
+
+// APIKeyRole is the role name a key's requests act under.
+func APIKeyRole(id uint) string {
+	return apiKeyRolePrefix + strconv.FormatUint(uint64(id), 10)
+}
+
+type APIKeyService interface {
+	GetAPIKeys() ([]models.APIKey, error)
+	CreateAPIKey(input dtos.CreateAPIKeyInput, actorRole string, actor common.Actor) (*dtos.APIKeyCreated, error)
+	RevokeAPIKey(id string, actor common.Actor) error
+	Authenticate(key string, clientIP string) (*models.APIKey, error)
+}
+
+type apiKeyService struct{}
+
+func NewAPIKeyService() APIKeyService {
+	return &apiKeyService{}
+}
+
+func (s *apiKeyService) GetAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := config.DB.Preload("Permissions").Order("id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateAPIKey issues a key with the given permissions, none of which may be
+// missing from the creator's own role. The key is returned once and only its hash
+// is kept.
+func (s *apiKeyService) CreateAPIKey(input dtos.CreateAPIKeyInput, actorRole string, actor common.Actor) (*dtos.APIKeyCreated, error) {
+	names, err := validatePermissions(input.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !roleCan(actorRole, name) {
+			return nil, fmt.Errorf("you cannot grant permission '%s' you do not have", name)
+		}
+	}
+
+	token, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	key := "kd_" + token
+
+	apiKey := models.APIKey{
+		Name:      strings.TrimSpace(input.Name),
+		Prefix:    key[:11],
+		KeyHash:   hashRefreshToken(key),
+		CreatedBy: actor.UserID,
+	}
+	if input.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *input.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	err = log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&apiKey).Error; err != nil {
+			return err
+		}
+		apiKey.Permissions = make([]models.APIKeyPermission, len(names))
+		for i, name := range names {
+			apiKey.Permissions[i] = models.APIKeyPermission{APIKeyID: apiKey.ID, Permission: name}
+		}
+		if len(apiKey.Permissions) > 0 {
+			if err := tx.Create(&apiKey.Permissions).Error; err != nil {
+				return err
+			}
+		}
+
+		description := fmt.Sprintf("API key '%s' created with %d permission(s)", apiKey.Name, len(names))
+		return log.CreateAuditLog(tx, "api_key", "create", apiKey.ID, nil, apiKey, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.APIKeyCreated{APIKey: apiKey, Key: key}, nil
+}
+
+// RevokeAPIKey stops the key from working at once.
+func (s *apiKeyService) RevokeAPIKey(id string, actor common.Actor) error {
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var apiKey models.APIKey
+		if err := tx.Preload("Permissions").First(&apiKey, id).Error; err != nil {
+			return errors.New("API key not found")
+		}
+		if apiKey.RevokedAt != nil {
+			return errors.New("API key is already revoked")
+		}
+		oldKey := apiKey
+
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		if err := tx.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("API key '%s' revoked", apiKey.Name)
+		return log.CreateAuditLog(tx, "api_key", "update", apiKey.ID, oldKey, apiKey, nil, actor, description)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateRolePermissions()
+	return nil
+}
+
+// Authenticate finds the active key and records when and from which address it
+// was last used, for admins reviewing their keys.
+func (s *apiKeyService) Authenticate(key string, clientIP string) (*models.APIKey, error) {
+	var apiKey models.APIKey
+	if err := config.DB.Where("key_hash = ?", hashRefreshToken(key)).First(&apiKey).Error; err != nil {
+		return nil, errors.New("Invalid API key")
+	}
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt)) {
+		return nil, errors.New("API key expired or revoked")
+	}
+
+	if err := config.DB.Model(&apiKey).Updates(map[string]any{
+		"last_used_at": now,
+		"last_used_ip": clientIP,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &apiKey, nil
+}
@@ -2,14 +2,19 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"kd-api/config"
 	"kd-api/dtos"
 	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type AttendanceService interface {
-	CreateAttendance(input dtos.CreateAttendanceInput) (*models.Attendance, error)
+	CreateAttendance(input dtos.CreateAttendanceInput, actor common.Actor) (*models.Attendance, error)
 	GetTodayAttendance() ([]models.Attendance, error)
 	GetAttendanceHistory() ([]models.Attendance, error)
 	GetAllAttendances() ([]models.Attendance, error)
@@ -21,7 +26,7 @@ func NewAttendanceService() AttendanceService {
 	return &attendanceService{}
 }
 
-func (s *attendanceService) CreateAttendance(input dtos.CreateAttendanceInput) (*models.Attendance, error) {
+func (s *attendanceService) CreateAttendance(input dtos.CreateAttendanceInput, actor common.Actor) (*models.Attendance, error) {
 	today := time.Now().Truncate(24 * time.Hour)
 
 	// cek absensi hari ini
@@ -37,7 +42,15 @@ func (s *attendanceService) CreateAttendance(input dtos.CreateAttendanceInput) (
 		Note:   &input.Note,
 	}
 
-	if err := config.DB.Create(&attendance).Error; err != nil {
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&attendance).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Attendance of user #%d on %s recorded as %s", attendance.UserID, today.Format("2006-01-02"), attendance.Status)
+		return log.CreateAuditLog(tx, "attendance", "create", attendance.ID, nil, attendance, nil, actor, description)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -2,16 +2,32 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"kd-api/config"
 	"kd-api/dtos"
 	"kd-api/models"
 	"kd-api/utils"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"math"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthService interface {
-	Login(input dtos.LoginInput) (*dtos.AuthResponse, error)
+	Login(input dtos.LoginInput, clientIP string, userAgent string) (*dtos.AuthResponse, error)
+	Refresh(input dtos.RefreshTokenInput, clientIP string) (*dtos.AuthResponse, error)
+	Logout(userID uint, sessionID uint, actor common.Actor) error
+	LogoutAll(userID uint, actor common.Actor) error
+	ChangePassword(userID uint, input dtos.ChangePasswordInput, actor common.Actor, userAgent string) (*dtos.AuthResponse, error)
+	PinLogin(input dtos.PinLoginInput, terminalToken string, clientIP string, userAgent string) (*dtos.AuthResponse, error)
+	UnlockSession(userID uint, sessionID uint, input dtos.UnlockSessionInput, clientIP string, userAgent string) error
+	SetPin(userID uint, input dtos.SetPinInput, actor common.Actor) error
+	VerifyTwoFactor(input dtos.VerifyTwoFactorInput, clientIP string, userAgent string) (*dtos.AuthResponse, error)
 }
 
 type authService struct{}
@@ -20,24 +36,413 @@ func NewAuthService() AuthService {
 	return &authService{}
 }
 
-func (s *authService) Login(input dtos.LoginInput) (*dtos.AuthResponse, error) {
+// Login checks the credentials. Unknown usernames and wrong passwords get the same
+// error, and repeated failures lock the username and the client address for a while.
+// Every attempt is recorded.
+func (s *authService) Login(input dtos.LoginInput, clientIP string, userAgent string) (*dtos.AuthResponse, error) {
+	username := normalizeLoginUsername(input.Username)
+
+	wait, err := loginLockout(username, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		recordLoginAttempt(username, nil, nil, clientIP, userAgent, "locked")
+		return nil, fmt.Errorf("Too many failed login attempts, try again in %d minute(s)", int(math.Ceil(wait.Minutes())))
+	}
+
 	var user models.User
-	if err := config.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
-		return nil, errors.New("User not found")
+	if err := config.DB.Where("username = ?", strings.TrimSpace(input.Username)).First(&user).Error; err != nil {
+		compareDummyPassword(input.Password)
+		recordLoginAttempt(username, nil, nil, clientIP, userAgent, "unknown_user")
+		return nil, errors.New("Invalid username or password")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		return nil, errors.New("Incorrect password")
+		recordLoginAttempt(username, &user.ID, nil, clientIP, userAgent, "wrong_password")
+		return nil, errors.New("Invalid username or password")
+	}
+
+	if !user.IsActive {
+		recordLoginAttempt(username, &user.ID, nil, clientIP, userAgent, "disabled")
+		return nil, errors.New("Account is disabled")
 	}
 
-	token, err := utils.GenerateToken(user.ID, user.Role)
+	// With two-factor authentication on, the session opens once the code checks out
+	if user.TOTPEnabled {
+		challenge, err := utils.GenerateTwoFactorToken(user.ID, user.TokenVersion)
+		if err != nil {
+			return nil, errors.New("Failed to generate token")
+		}
+		return &dtos.AuthResponse{
+			Message:           "Enter your two-factor code",
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		}, nil
+	}
+
+	actor := common.Actor{UserID: &user.ID, IPAddress: clientIP}
+	response, err := startSession(log.WithActor(config.DB, actor), user, clientIP, userAgent, nil)
+	if err != nil {
+		return nil, err
+	}
+	recordLoginAttempt(username, &user.ID, nil, clientIP, userAgent, "ok")
+	response.Message = "Login successful"
+	return response, nil
+}
+
+// VerifyTwoFactor completes a login with the challenge token and an authenticator
+// or recovery code. Wrong codes count towards the login lockout like wrong passwords.
+func (s *authService) VerifyTwoFactor(input dtos.VerifyTwoFactorInput, clientIP string, userAgent string) (*dtos.AuthResponse, error) {
+	claims, err := utils.VerifyTwoFactorToken(input.ChallengeToken)
+	if err != nil {
+		return nil, errors.New("Invalid or expired two-factor challenge")
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, claims.UserID).Error; err != nil {
+		return nil, errors.New("Invalid or expired two-factor challenge")
+	}
+	username := normalizeLoginUsername(user.Username)
+
+	wait, err := loginLockout(username, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		recordLoginAttempt(username, &user.ID, nil, clientIP, userAgent, "locked")
+		return nil, fmt.Errorf("Too many failed login attempts, try again in %d minute(s)", int(math.Ceil(wait.Minutes())))
+	}
+
+	var response *dtos.AuthResponse
+	reason := "ok"
+	actor := common.Actor{UserID: &user.ID, IPAddress: clientIP}
+	err = log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		// Locked so a code cannot be used twice by parallel requests
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, user.ID).Error; err != nil {
+			return errors.New("Invalid or expired two-factor challenge")
+		}
+		// A password change since the challenge was issued ends it
+		if user.TokenVersion != claims.TokenVersion {
+			return errors.New("Invalid or expired two-factor challenge")
+		}
+		if !user.IsActive {
+			reason = "disabled"
+			return errors.New("Account is disabled")
+		}
+
+		ok, err := checkSecondFactor(tx, &user, input.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			reason = "wrong_2fa"
+			return errors.New("Invalid two-factor code")
+		}
+
+		response, err = startSession(tx, user, clientIP, userAgent, nil)
+		return err
+	})
 	if err != nil {
-		return nil, errors.New("Failed to generate token")
+		if reason != "ok" {
+			recordLoginAttempt(username, &user.ID, nil, clientIP, userAgent, reason)
+		}
+		return nil, err
 	}
 
-	return &dtos.AuthResponse{
-		Message: "Login successful",
-		Token:   token,
-		Role:    user.Role,
-	}, nil
+	recordLoginAttempt(username, &user.ID, nil, clientIP, userAgent, "ok")
+	response.Message = "Login successful"
+	return response, nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh token.
+// Each refresh token works once; presenting an already rotated one means it was
+// copied, so the whole session is revoked.
+func (s *authService) Refresh(input dtos.RefreshTokenInput, clientIP string) (*dtos.AuthResponse, error) {
+	hash := hashRefreshToken(input.RefreshToken)
+
+	var response *dtos.AuthResponse
+	reused := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var session models.Session
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+			var rotated models.Session
+			reused = tx.Where("previous_refresh_hash = ? AND revoked_at IS NULL", hash).
+				First(&rotated).Error == nil
+			return errors.New("Invalid refresh token")
+		}
+
+		now := time.Now()
+		if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+			return errors.New("Refresh token expired or revoked")
+		}
+		if sessionIdle(session) {
+			return errors.New("Session is locked, enter your PIN to continue")
+		}
+
+		var user models.User
+		if err := tx.First(&user, session.UserID).Error; err != nil || !user.IsActive {
+			return errors.New("Account is disabled")
+		}
+
+		refreshToken, err := newRefreshToken()
+		if err != nil {
+			return err
+		}
+		// PIN sessions end at a fixed time, refreshing does not extend them
+		expiresAt := now.Add(utils.RefreshTokenTTL())
+		if session.TerminalID != nil {
+			expiresAt = session.ExpiresAt
+		}
+		actor := common.Actor{UserID: &user.ID, IPAddress: clientIP, TerminalID: session.TerminalID}
+		if err := log.WithActor(tx, actor).Model(&session).Updates(map[string]any{
+			"refresh_token_hash":    hashRefreshToken(refreshToken),
+			"previous_refresh_hash": hash,
+			"expires_at":            expiresAt,
+			"last_used_at":          now,
+			"ip_address":            clientIP,
+		}).Error; err != nil {
+			return err
+		}
+
+		response, err = sessionTokens(user, session, refreshToken)
+		return err
+	})
+	if reused {
+		// A rotated token was replayed, end the session it belongs to
+		config.DB.Model(&models.Session{}).
+			Where("previous_refresh_hash = ? AND revoked_at IS NULL", hash).
+			Update("revoked_at", time.Now())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	response.Message = "Token refreshed"
+	return response, nil
+}
+
+// Logout ends the session of the token used for the request.
+func (s *authService) Logout(userID uint, sessionID uint, actor common.Actor) error {
+	if sessionID == 0 {
+		return nil
+	}
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		var user models.User
+		if err := tx.Select("id", "username").First(&user, userID).Error; err != nil {
+			return err
+		}
+		description := fmt.Sprintf("User '%s' logged out", user.Username)
+		return log.CreateUserAuditLog(tx, "logout", user.ID, nil, nil, actor, description)
+	})
+}
+
+// LogoutAll ends every session of the user, on every device.
+func (s *authService) LogoutAll(userID uint, actor common.Actor) error {
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return errors.New("User not found")
+		}
+		if err := revokeAllAccess(tx, user.ID); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("User '%s' logged out of all sessions", user.Username)
+		return log.CreateUserAuditLog(tx, "logout", user.ID, nil, nil, actor, description)
+	})
+}
+
+// ChangePassword lets a user replace their own password. Every session is ended,
+// so a fresh one is returned.
+func (s *authService) ChangePassword(userID uint, input dtos.ChangePasswordInput, actor common.Actor, userAgent string) (*dtos.AuthResponse, error) {
+	var response *dtos.AuthResponse
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return errors.New("User not found")
+		}
+		oldUser := user
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+			return errors.New("Current password is incorrect")
+		}
+
+		if err := setPassword(tx, &user, input.NewPassword, false); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("User '%s' changed their password", user.Username)
+		if err := log.CreateUserAuditLog(tx, "update", user.ID, &oldUser, &user, actor, description); err != nil {
+			return err
+		}
+
+		var err error
+		response, err = startSession(tx, user, actor.IPAddress, userAgent, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response.Message = "Password changed successfully"
+	return response, nil
+}
+
+// PinLogin logs a user in with their PIN on a registered terminal. A terminal is
+// used by one cashier at a time, so its open sessions end, and so do the user's
+// sessions on other terminals. Failures count towards the same lockout as
+// password logins.
+func (s *authService) PinLogin(input dtos.PinLoginInput, terminalToken string, clientIP string, userAgent string) (*dtos.AuthResponse, error) {
+	terminal, err := terminalByToken(terminalToken)
+	if err != nil {
+		return nil, err
+	}
+
+	username := normalizeLoginUsername(input.Username)
+	wait, err := loginLockout(username, clientIP)
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		recordLoginAttempt(username, nil, &terminal.ID, clientIP, userAgent, "locked")
+		return nil, fmt.Errorf("Too many failed login attempts, try again in %d minute(s)", int(math.Ceil(wait.Minutes())))
+	}
+
+	var user models.User
+	if err := config.DB.Where("username = ?", strings.TrimSpace(input.Username)).First(&user).Error; err != nil {
+		compareDummyPassword(input.Pin)
+		recordLoginAttempt(username, nil, &terminal.ID, clientIP, userAgent, "unknown_user")
+		return nil, errors.New("Invalid username or PIN")
+	}
+
+	if !pinMatches(user, input.Pin) {
+		recordLoginAttempt(username, &user.ID, &terminal.ID, clientIP, userAgent, "wrong_pin")
+		return nil, errors.New("Invalid username or PIN")
+	}
+
+	if !user.IsActive {
+		recordLoginAttempt(username, &user.ID, &terminal.ID, clientIP, userAgent, "disabled")
+		return nil, errors.New("Account is disabled")
+	}
+
+	var response *dtos.AuthResponse
+	actor := common.Actor{UserID: &user.ID, IPAddress: clientIP, TerminalID: &terminal.ID}
+	err = log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.Session{}).
+			Where("revoked_at IS NULL AND (terminal_id = ? OR (user_id = ? AND terminal_id IS NOT NULL))", terminal.ID, user.ID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(terminal).Update("last_seen_at", now).Error; err != nil {
+			return err
+		}
+
+		var err error
+		response, err = startSession(tx, user, clientIP, userAgent, &terminal.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recordLoginAttempt(username, &user.ID, &terminal.ID, clientIP, userAgent, "ok")
+	response.Message = "Login successful"
+	return response, nil
+}
+
+// pinMatches checks the PIN, spending the same time when the user has none.
+func pinMatches(user models.User, pin string) bool {
+	if user.PinHash == nil {
+		compareDummyPassword(pin)
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(*user.PinHash), []byte(pin)) == nil
+}
+
+// UnlockSession reopens a terminal session locked for inactivity once its user
+// enters the PIN again.
+func (s *authService) UnlockSession(userID uint, sessionID uint, input dtos.UnlockSessionInput, clientIP string, userAgent string) error {
+	var session models.Session
+	if err := config.DB.First(&session, sessionID).Error; err != nil || session.UserID != userID || session.RevokedAt != nil {
+		return errors.New("Session not found")
+	}
+	if session.TerminalID == nil {
+		return errors.New("only terminal sessions can be unlocked with a PIN")
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return errors.New("User not found")
+	}
+
+	username := normalizeLoginUsername(user.Username)
+	wait, err := loginLockout(username, clientIP)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		recordLoginAttempt(username, &user.ID, session.TerminalID, clientIP, userAgent, "locked")
+		return fmt.Errorf("Too many failed login attempts, try again in %d minute(s)", int(math.Ceil(wait.Minutes())))
+	}
+
+	if !pinMatches(user, input.Pin) {
+		recordLoginAttempt(username, &user.ID, session.TerminalID, clientIP, userAgent, "wrong_pin")
+		return errors.New("Invalid PIN")
+	}
+
+	if err := config.DB.Model(&session).Updates(map[string]any{
+		"locked_at":    nil,
+		"last_used_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	recordLoginAttempt(username, &user.ID, session.TerminalID, clientIP, userAgent, "ok")
+	return nil
+}
+
+// SetPin sets the user's own terminal PIN, confirmed with their password.
+func (s *authService) SetPin(userID uint, input dtos.SetPinInput, actor common.Actor) error {
+	if err := utils.ValidatePin(input.Pin); err != nil {
+		return err
+	}
+
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return errors.New("User not found")
+		}
+		oldUser := user
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+			return errors.New("Current password is incorrect")
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(input.Pin), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		pinHash := string(hash)
+		now := time.Now()
+		user.PinHash = &pinHash
+		user.PinSetAt = &now
+		if err := tx.Model(&user).Updates(map[string]any{
+			"pin_hash":   user.PinHash,
+			"pin_set_at": user.PinSetAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("User '%s' set their PIN", user.Username)
+		return log.CreateUserAuditLog(tx, "update", user.ID, &oldUser, &user, actor, description)
+	})
 }
@@ -2,18 +2,21 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"kd-api/config"
 	"kd-api/dtos"
 	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
 	"time"
 
 	"gorm.io/gorm"
 )
 
 type CashSessionService interface {
-	OpenCashSession(input dtos.OpenCashSessionInput, userID uint) (*models.CashSession, error)
+	OpenCashSession(input dtos.OpenCashSessionInput, userID uint, actor common.Actor) (*models.CashSession, error)
 	GetCurrentSession(userID uint) (*models.CashSession, error)
-	CloseCashSession(input dtos.CloseCashSessionInput, userID uint) (*models.CashSession, error)
+	CloseCashSession(input dtos.CloseCashSessionInput, userID uint, actor common.Actor) (*models.CashSession, error)
 	GetSessionHistory(filter dtos.CashSessionHistoryFilter, userID uint) (*dtos.CashSessionListResponse, error)
 }
 
@@ -23,7 +26,7 @@ func NewCashSessionService() CashSessionService {
 	return &cashSessionService{}
 }
 
-func (s *cashSessionService) OpenCashSession(input dtos.OpenCashSessionInput, userID uint) (*models.CashSession, error) {
+func (s *cashSessionService) OpenCashSession(input dtos.OpenCashSessionInput, userID uint, actor common.Actor) (*models.CashSession, error) {
 	var existing models.CashSession
 	err := config.DB.Where("user_id = ? AND status = 'open'", userID).
 		First(&existing).Error
@@ -36,14 +39,28 @@ func (s *cashSessionService) OpenCashSession(input dtos.OpenCashSessionInput, us
 		return nil, err
 	}
 
+	locationID, err := resolveLocationID(config.DB, input.LocationID, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	session := models.CashSession{
 		UserID:      userID,
+		LocationID:  &locationID,
 		OpeningCash: input.OpeningCash,
 		Status:      "open",
 		OpenedAt:    time.Now(),
 	}
 
-	if err := config.DB.Create(&session).Error; err != nil {
+	err = log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Cash session #%d opened with %.2f", session.ID, session.OpeningCash)
+		return log.CreateAuditLog(tx, "cash_session", "open", session.ID, nil, session, nil, actor, description)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -62,7 +79,7 @@ func (s *cashSessionService) GetCurrentSession(userID uint) (*models.CashSession
 	return &session, nil
 }
 
-func (s *cashSessionService) CloseCashSession(input dtos.CloseCashSessionInput, userID uint) (*models.CashSession, error) {
+func (s *cashSessionService) CloseCashSession(input dtos.CloseCashSessionInput, userID uint, actor common.Actor) (*models.CashSession, error) {
 	var session models.CashSession
 	if err := config.DB.Where("user_id = ? AND status = 'open'", userID).
 		First(&session).Error; err != nil {
@@ -91,6 +108,7 @@ func (s *cashSessionService) CloseCashSession(input dtos.CloseCashSessionInput,
 
 	diff := input.ClosingCash - expected
 
+	oldSession := session
 	session.TotalCashIn = result.TotalCashIn
 	session.TotalChange = result.TotalChange
 	session.ExpectedCash = expected
@@ -101,7 +119,15 @@ func (s *cashSessionService) CloseCashSession(input dtos.CloseCashSessionInput,
 	now := time.Now()
 	session.ClosedAt = &now
 
-	if err := config.DB.Save(&session).Error; err != nil {
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&session).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Cash session #%d closed with %.2f, difference %.2f", session.ID, input.ClosingCash, diff)
+		return log.CreateAuditLog(tx, "cash_session", "close", session.ID, oldSession, session, nil, actor, description)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"kd-api/models"
+	"os"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CostingService interface {
+	RecordOpeningStock(tx *gorm.DB, itemID uint, quantity int, unitCost float64, refID string) error
+	RecordReceipt(tx *gorm.DB, itemID uint, quantity int, unitCost float64, refID string) error
+	CostOfSale(tx *gorm.DB, itemID uint, quantity int) (float64, error)
+	ReturnToStock(tx *gorm.DB, itemID uint, quantity int, unitCost float64, refID string) error
+}
+
+type costingService struct {
+	method string
+}
+
+func NewCostingService() CostingService {
+	return &costingService{method: costingMethod()}
+}
+
+// costingMethod reads COSTING_METHOD, either "average" (default) or "fifo".
+func costingMethod() string {
+	if os.Getenv("COSTING_METHOD") == "fifo" {
+		return "fifo"
+	}
+	return "average"
+}
+
+// RecordOpeningStock sets the cost of a newly created item whose stock is already
+// on the item row.
+func (s *costingService) RecordOpeningStock(tx *gorm.DB, itemID uint, quantity int, unitCost float64, refID string) error {
+	if err := tx.Model(&models.Item{}).Where("id = ?", itemID).
+		Update("average_cost", unitCost).Error; err != nil {
+		return err
+	}
+
+	if quantity <= 0 {
+		return nil
+	}
+
+	layer := models.CostLayer{
+		ItemID:      itemID,
+		Quantity:    quantity,
+		Remaining:   quantity,
+		UnitCost:    unitCost,
+		ReferenceID: refID,
+	}
+	return tx.Create(&layer).Error
+}
+
+// RecordReceipt must be called before the received quantity is added to stock,
+// the weighted average is taken over the stock on hand at that moment.
+func (s *costingService) RecordReceipt(tx *gorm.DB, itemID uint, quantity int, unitCost float64, refID string) error {
+	if quantity <= 0 {
+		return nil
+	}
+
+	var item models.Item
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, itemID).Error; err != nil {
+		return err
+	}
+
+	onHand := item.Stock
+	if onHand < 0 {
+		onHand = 0
+	}
+	average := (float64(onHand)*item.AverageCost + float64(quantity)*unitCost) / float64(onHand+quantity)
+
+	if err := tx.Model(&models.Item{}).Where("id = ?", itemID).
+		Update("average_cost", average).Error; err != nil {
+		return err
+	}
+
+	layer := models.CostLayer{
+		ItemID:      itemID,
+		Quantity:    quantity,
+		Remaining:   quantity,
+		UnitCost:    unitCost,
+		ReferenceID: refID,
+	}
+	return tx.Create(&layer).Error
+}
+
+// CostOfSale consumes cost layers oldest first and returns the unit cost of the
+// sold quantity under the configured method. Quantity not covered by any layer is
+// costed at the item's average cost.
+func (s *costingService) CostOfSale(tx *gorm.DB, itemID uint, quantity int) (float64, error) {
+	var item models.Item
+	if err := tx.First(&item, itemID).Error; err != nil {
+		return 0, err
+	}
+
+	if quantity <= 0 {
+		return item.AverageCost, nil
+	}
+
+	var layers []models.CostLayer
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("item_id = ? AND remaining > 0", itemID).
+		Order("created_at, id").
+		Find(&layers).Error; err != nil {
+		return 0, err
+	}
+
+	remaining := quantity
+	var fifoCost float64
+	for _, layer := range layers {
+		if remaining == 0 {
+			break
+		}
+
+		take := layer.Remaining
+		if take > remaining {
+			take = remaining
+		}
+
+		if err := tx.Model(&models.CostLayer{}).Where("id = ?", layer.ID).
+			Update("remaining", layer.Remaining-take).Error; err != nil {
+			return 0, err
+		}
+
+		fifoCost += float64(take) * layer.UnitCost
+		remaining -= take
+	}
+	fifoCost += float64(remaining) * item.AverageCost
+
+	if s.method == "fifo" {
+		return fifoCost / float64(quantity), nil
+	}
+	return item.AverageCost, nil
+}
+
+// ReturnToStock puts refunded quantity back at the cost it was sold for.
+func (s *costingService) ReturnToStock(tx *gorm.DB, itemID uint, quantity int, unitCost float64, refID string) error {
+	return s.RecordReceipt(tx, itemID, quantity, unitCost, refID)
+}
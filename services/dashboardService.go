@@ -27,7 +27,7 @@ func (s *dashboardService) GetDashboardStats() (*dtos.DashboardStats, error) {
 	var todayTransactionsData []models.Transaction
 
 	// Calculate today's profit
-	if err := config.DB.Preload("Items.Item").
+	if err := config.DB.Preload("Items").
 		Where("status = ? AND DATE(created_at) = ?", "completed", today).
 		Find(&todayTransactionsData).Error; err != nil {
 		return nil, err
@@ -35,7 +35,8 @@ func (s *dashboardService) GetDashboardStats() (*dtos.DashboardStats, error) {
 
 	for _, t := range todayTransactionsData {
 		for _, ti := range t.Items {
-			todayProfit += float64(ti.Quantity) * (ti.Price - ti.Item.BuyPrice)
+			// Cost is snapshot on the line when the sale completes
+			todayProfit += float64(ti.Quantity) * (ti.Price - ti.UnitCost)
 		}
 	}
 
@@ -46,8 +47,9 @@ func (s *dashboardService) GetDashboardStats() (*dtos.DashboardStats, error) {
 		return nil, err
 	}
 
-	// Count low stock items (<5)
-	if err := config.DB.Model(&models.Item{}).Where("stock < ?", 5).Count(&lowStock).Error; err != nil {
+	// Count low stock items (per-item reorder point / minimum stock)
+	lowStockSQL, lowStockArgs := lowStockCondition()
+	if err := config.DB.Model(&models.Item{}).Where(lowStockSQL, lowStockArgs...).Count(&lowStock).Error; err != nil {
 		return nil, err
 	}
 
@@ -66,7 +68,7 @@ func (s *dashboardService) GetDashboardStats() (*dtos.DashboardStats, error) {
 	// Populate item names for top items
 	for i, ti := range topItems {
 		var item models.Item
-		if err := config.DB.First(&item, ti.ItemID).Error; err == nil {
+		if err := config.DB.Unscoped().First(&item, ti.ItemID).Error; err == nil {
 			topItems[i].Name = item.Name
 		}
 	}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type GoodsReceiptService interface {
+	CreateReceipt(input dtos.CreateGoodsReceiptInput, actor common.Actor) (*models.GoodsReceipt, error)
+	GetReceipts(filter dtos.GoodsReceiptFilter) (*dtos.GoodsReceiptListResponse, error)
+	GetReceiptByID(id string) (*models.GoodsReceipt, error)
+}
+
+type goodsReceiptService struct{}
+
+func NewGoodsReceiptService() GoodsReceiptService {
+	return &goodsReceiptService{}
+}
+
+func (s *goodsReceiptService) CreateReceipt(input dtos.CreateGoodsReceiptInput, actor common.Actor) (*models.GoodsReceipt, error) {
+	var receipt models.GoodsReceipt
+
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		locationID, err := resolveLocationID(tx, input.LocationID, nil)
+		if err != nil {
+			return err
+		}
+
+		receipt = models.GoodsReceipt{
+			LocationID:  locationID,
+			ReferenceNo: input.ReferenceNo,
+			Note:        input.Note,
+			ReceivedBy:  actor.UserID,
+		}
+		for _, i := range input.Items {
+			var item models.Item
+			if err := tx.First(&item, i.ItemID).Error; err != nil {
+				return fmt.Errorf("item %d not found", i.ItemID)
+			}
+
+			if i.ExpiryDate != nil && *i.ExpiryDate != "" {
+				if _, err := time.Parse("2006-01-02", *i.ExpiryDate); err != nil {
+					return errors.New("Invalid date format, use YYYY-MM-DD")
+				}
+			}
+
+			if item.IsKit {
+				return fmt.Errorf("item '%s' is a kit, receive its components instead", item.Name)
+			}
+			if item.IsSerialized && len(i.Serials) != i.Quantity {
+				return fmt.Errorf("item '%s' needs one serial number per unit received", item.Name)
+			}
+
+			receipt.Items = append(receipt.Items, models.GoodsReceiptItem{
+				ItemID:   i.ItemID,
+				Quantity: i.Quantity,
+				UnitCost: i.UnitCost,
+			})
+		}
+
+		if err := tx.Create(&receipt).Error; err != nil {
+			return err
+		}
+
+		invService := NewInventoryService()
+		costing := NewCostingService()
+		ref := fmt.Sprintf("GR-%d", receipt.ID)
+		for idx, line := range receipt.Items {
+			var lotID *uint
+			if lot, err := createReceiptLot(tx, input.Items[idx], &receipt, idx); err != nil {
+				return err
+			} else if lot != nil {
+				lotID = &lot.ID
+				if err := tx.Model(&models.GoodsReceiptItem{}).Where("id = ?", line.ID).
+					Update("lot_id", lot.ID).Error; err != nil {
+					return err
+				}
+			}
+
+			if len(input.Items[idx].Serials) > 0 {
+				if err := NewSerialService().RegisterSerials(tx, line.ItemID, input.Items[idx].Serials, &receipt.ID); err != nil {
+					return err
+				}
+			}
+
+			// Cost first: the average is weighted over the stock before the receipt
+			if err := costing.RecordReceipt(tx, line.ItemID, line.Quantity, line.UnitCost, ref); err != nil {
+				return err
+			}
+
+			applied, err := invService.AdjustStock(tx, line.ItemID, locationID, line.Quantity)
+			if err != nil {
+				return err
+			}
+			if err := invService.LogStockChange(tx, line.ItemID, applied, "restock", ref, actor.UserID, "Goods receipt", &locationID, lotID); err != nil {
+				return err
+			}
+		}
+
+		description := fmt.Sprintf("Goods receipt %s recorded", ref)
+		return log.CreateAuditLog(tx, "goods_receipt", "create", receipt.ID, nil, receipt, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReceiptByID(fmt.Sprint(receipt.ID))
+}
+
+func (s *goodsReceiptService) GetReceipts(filter dtos.GoodsReceiptFilter) (*dtos.GoodsReceiptListResponse, error) {
+	var receipts []models.GoodsReceipt
+	var total int64
+
+	db := config.DB.Model(&models.GoodsReceipt{})
+
+	if filter.StartDate != "" {
+		db = db.Where("created_at >= ?", filter.StartDate+" 00:00:00")
+	}
+	if filter.EndDate != "" {
+		db = db.Where("created_at <= ?", filter.EndDate+" 23:59:59")
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 10
+	}
+	offset := (filter.Page - 1) * filter.Limit
+
+	if err := db.Preload("Items.Item", withDeleted).Preload("Location").
+		Order("created_at DESC").
+		Limit(filter.Limit).
+		Offset(offset).
+		Find(&receipts).Error; err != nil {
+		return nil, err
+	}
+
+	return &dtos.GoodsReceiptListResponse{
+		Data:       receipts,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: int((total + int64(filter.Limit) - 1) / int64(filter.Limit)),
+	}, nil
+}
+
+func (s *goodsReceiptService) GetReceiptByID(id string) (*models.GoodsReceipt, error) {
+	var receipt models.GoodsReceipt
+	if err := config.DB.Preload("Items.Item", withDeleted).Preload("Items.Lot").Preload("Location").
+		First(&receipt, id).Error; err != nil {
+		return nil, errors.New("goods receipt not found")
+	}
+	return &receipt, nil
+}
+
+// createReceiptLot starts a lot for a receipt line that has a batch number or an
+// expiry date. Lines with neither are received untracked.
+func createReceiptLot(tx *gorm.DB, input dtos.GoodsReceiptItemInput, receipt *models.GoodsReceipt, idx int) (*models.Lot, error) {
+	hasBatch := input.BatchNumber != nil && *input.BatchNumber != ""
+	hasExpiry := input.ExpiryDate != nil && *input.ExpiryDate != ""
+	if !hasBatch && !hasExpiry {
+		return nil, nil
+	}
+
+	batchNumber := fmt.Sprintf("GR-%d-%d", receipt.ID, idx+1)
+	if hasBatch {
+		batchNumber = *input.BatchNumber
+	}
+
+	var expiryDate *time.Time
+	if hasExpiry {
+		date, _ := time.Parse("2006-01-02", *input.ExpiryDate)
+		expiryDate = &date
+	}
+
+	return NewLotService().CreateLot(tx, input.ItemID, receipt.LocationID, batchNumber, expiryDate, input.Quantity, &receipt.ID)
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"kd-api/config"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"kd-api/utils/storage"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"gorm.io/gorm"
+)
+
+const (
+	thumbnailSize  = 300        // longest side of a thumbnail, in pixels
+	maxImagePixels = 40_000_000 // refuse images that would take too much memory to decode
+)
+
+// Accepted upload types and the extension they are stored with
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type ImageService interface {
+	UploadItemImage(itemID string, file *multipart.FileHeader, actor common.Actor) (*models.Item, error)
+	OpenItemImage(itemID string, thumbnail bool) (io.ReadCloser, string, error)
+}
+
+type imageService struct {
+	storage storage.Storage
+}
+
+func NewImageService() ImageService {
+	return &imageService{storage: storage.FromEnv()}
+}
+
+// maxImageSize reads IMAGE_MAX_SIZE_MB, default 5.
+func maxImageSize() int64 {
+	mb, err := strconv.Atoi(os.Getenv("IMAGE_MAX_SIZE_MB"))
+	if err != nil || mb < 1 {
+		mb = 5
+	}
+	return int64(mb) << 20
+}
+
+// UploadItemImage stores the original and a JPEG thumbnail, then points the item's
+// ImageURL and ThumbnailURL at the public image endpoints. The previous files are
+// removed once the item is updated.
+func (s *imageService) UploadItemImage(itemID string, file *multipart.FileHeader, actor common.Actor) (*models.Item, error) {
+	var item models.Item
+	if err := config.DB.First(&item, itemID).Error; err != nil {
+		return nil, errors.New("Item not found")
+	}
+
+	maxSize := maxImageSize()
+	if file.Size > maxSize {
+		return nil, fmt.Errorf("image too large, max %d MB", maxSize>>20)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("image too large, max %d MB", maxSize>>20)
+	}
+
+	// Trust the bytes, not the client's Content-Type header
+	contentType := http.DetectContentType(data)
+	ext, ok := imageExtensions[contentType]
+	if !ok {
+		return nil, errors.New("unsupported image type, use JPEG, PNG, GIF or WebP")
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image")
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, errors.New("image dimensions too large")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image")
+	}
+
+	thumbnail, err := makeThumbnail(img)
+	if err != nil {
+		return nil, err
+	}
+
+	version := time.Now().UnixNano()
+	imageKey := fmt.Sprintf("items/%d/%d%s", item.ID, version, ext)
+	thumbnailKey := fmt.Sprintf("items/%d/%d_thumb.jpg", item.ID, version)
+
+	if err := s.storage.Put(imageKey, data, contentType); err != nil {
+		return nil, err
+	}
+	if err := s.storage.Put(thumbnailKey, thumbnail, "image/jpeg"); err != nil {
+		s.storage.Delete(imageKey)
+		return nil, err
+	}
+
+	oldItem := item
+	imageURL := fmt.Sprintf("/public/items/%d/image?v=%d", item.ID, version)
+	thumbnailURL := fmt.Sprintf("/public/items/%d/image/thumbnail?v=%d", item.ID, version)
+	item.ImageURL = &imageURL
+	item.ThumbnailURL = &thumbnailURL
+	item.ImageKey = &imageKey
+	item.ThumbnailKey = &thumbnailKey
+
+	err = log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Item{}).Where("id = ?", item.ID).Updates(map[string]any{
+			"image_url":     imageURL,
+			"thumbnail_url": thumbnailURL,
+			"image_key":     imageKey,
+			"thumbnail_key": thumbnailKey,
+		}).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Image uploaded for item '%s'", item.Name)
+		return log.CreateItemAuditLog(tx, "update", item.ID, &oldItem, &item, actor, description)
+	})
+	if err != nil {
+		s.storage.Delete(imageKey)
+		s.storage.Delete(thumbnailKey)
+		return nil, err
+	}
+
+	// Old files are no longer referenced, a failed delete only leaves garbage behind
+	if oldItem.ImageKey != nil {
+		s.storage.Delete(*oldItem.ImageKey)
+	}
+	if oldItem.ThumbnailKey != nil {
+		s.storage.Delete(*oldItem.ThumbnailKey)
+	}
+
+	return &item, nil
+}
+
+// OpenItemImage returns the stored image (or its thumbnail) of an item.
+func (s *imageService) OpenItemImage(itemID string, thumbnail bool) (io.ReadCloser, string, error) {
+	var item models.Item
+	if err := config.DB.First(&item, itemID).Error; err != nil {
+		return nil, "", errors.New("Item not found")
+	}
+
+	key := item.ImageKey
+	if thumbnail {
+		key = item.ThumbnailKey
+	}
+	if key == nil {
+		return nil, "", errors.New("image not found")
+	}
+
+	body, contentType, err := s.storage.Get(*key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, "", errors.New("image not found")
+	}
+	return body, contentType, err
+}
+
+// makeThumbnail scales the image to fit thumbnailSize and encodes it as JPEG.
+// Transparent areas become white.
+func makeThumbnail(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > thumbnailSize || height > thumbnailSize {
+		if width >= height {
+			height = max(1, height*thumbnailSize/width)
+			width = thumbnailSize
+		} else {
+			width = max(1, width*thumbnailSize/height)
+			height = thumbnailSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -7,11 +7,14 @@ import (
 	"kd-api/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type InventoryService interface {
-	LogStockChange(tx *gorm.DB, itemID uint, change int, logType string, refID string, userID *uint, note string) error
+	LogStockChange(tx *gorm.DB, itemID uint, change int, logType string, refID string, userID *uint, note string, locationID *uint, lotID *uint) error
+	AdjustStock(tx *gorm.DB, itemID uint, locationID uint, change int) (int, error)
 	GetInventoryHistory(filter dtos.InventoryFilter) (*dtos.InventoryListResponse, error)
+	ReconcileInventory(input dtos.ReconcileInventoryInput, userID *uint) (*dtos.InventoryReconcileResponse, error)
 }
 
 type inventoryService struct{}
@@ -44,6 +47,14 @@ func (s *inventoryService) GetInventoryHistory(filter dtos.InventoryFilter) (*dt
 		db = db.Where("type = ?", filter.Type)
 	}
 
+	if filter.LocationID != 0 {
+		db = db.Where("location_id = ?", filter.LocationID)
+	}
+
+	if filter.LotID != 0 {
+		db = db.Where("lot_id = ?", filter.LotID)
+	}
+
 	if err := db.Count(&total).Error; err != nil {
 		return nil, err
 	}
@@ -56,7 +67,7 @@ func (s *inventoryService) GetInventoryHistory(filter dtos.InventoryFilter) (*dt
 	}
 	offset := (filter.Page - 1) * filter.Limit
 
-	if err := db.Preload("User").
+	if err := db.Preload("User").Preload("Location").Preload("Lot").
 		Order("created_at DESC").
 		Limit(filter.Limit).
 		Offset(offset).
@@ -73,7 +84,7 @@ func (s *inventoryService) GetInventoryHistory(filter dtos.InventoryFilter) (*dt
 	}, nil
 }
 
-func (s *inventoryService) LogStockChange(tx *gorm.DB, itemID uint, change int, logType string, refID string, userID *uint, note string) error {
+func (s *inventoryService) LogStockChange(tx *gorm.DB, itemID uint, change int, logType string, refID string, userID *uint, note string, locationID *uint, lotID *uint) error {
 	// 1. Get current stock to ensure accuracy (locking row would be ideal but simple read is start)
 	var item models.Item
 	if err := tx.First(&item, itemID).Error; err != nil {
@@ -86,6 +97,8 @@ func (s *inventoryService) LogStockChange(tx *gorm.DB, itemID uint, change int,
 		Change:      change,
 		FinalStock:  item.Stock, // This assumes the item.Stock has already been updated in the DB by the caller
 		Type:        logType,
+		LocationID:  locationID,
+		LotID:       lotID,
 		ReferenceID: refID,
 		UserID:      userID,
 		Note:        note,
@@ -97,3 +110,194 @@ func (s *inventoryService) LogStockChange(tx *gorm.DB, itemID uint, change int,
 
 	return nil
 }
+
+// AdjustStock adds change to the item's stock at a location, never going below zero,
+// and refreshes Item.Stock as the sum over all locations. It returns the change that
+// was actually applied.
+func (s *inventoryService) AdjustStock(tx *gorm.DB, itemID uint, locationID uint, change int) (int, error) {
+	var level models.ItemStock
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("item_id = ? AND location_id = ?", itemID, locationID).
+		First(&level).Error
+	if err == gorm.ErrRecordNotFound {
+		level = models.ItemStock{ItemID: itemID, LocationID: locationID}
+	} else if err != nil {
+		return 0, err
+	}
+
+	applied := change
+	if level.Quantity+change < 0 {
+		applied = -level.Quantity
+	}
+	level.Quantity += applied
+
+	if err := tx.Save(&level).Error; err != nil {
+		return 0, err
+	}
+
+	var total int64
+	if err := tx.Model(&models.ItemStock{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("item_id = ?", itemID).
+		Scan(&total).Error; err != nil {
+		return 0, err
+	}
+
+	if err := tx.Model(&models.Item{}).Where("id = ?", itemID).
+		Update("stock", total).Error; err != nil {
+		return 0, err
+	}
+
+	return applied, nil
+}
+
+// ReconcileInventory compares Item.Stock with the sum of the item's ledger entries.
+// When input.Fix is set, "audit" entries are posted for every discrepancy, at the
+// locations it was found in, so the ledger sums up to the current stock again.
+func (s *inventoryService) ReconcileInventory(input dtos.ReconcileInventoryInput, userID *uint) (*dtos.InventoryReconcileResponse, error) {
+	var checked int64
+	countQuery := config.DB.Model(&models.Item{})
+	if input.ItemID != nil {
+		countQuery = countQuery.Where("id = ?", *input.ItemID)
+	}
+	if err := countQuery.Count(&checked).Error; err != nil {
+		return nil, err
+	}
+
+	discrepancies, err := findStockDiscrepancies(config.DB, input.ItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	fixed := 0
+	if input.Fix {
+		for i := range discrepancies {
+			d := &discrepancies[i]
+			err := config.DB.Transaction(func(tx *gorm.DB) error {
+				// Lock the item and re-check, stock may have moved since the report
+				var item models.Item
+				if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, d.ItemID).Error; err != nil {
+					return err
+				}
+
+				current, err := findStockDiscrepancies(tx, &d.ItemID)
+				if err != nil {
+					return err
+				}
+				if len(current) == 0 {
+					return nil
+				}
+
+				corrections, err := reconcileLocations(tx, d.ItemID, current[0].Difference)
+				if err != nil {
+					return err
+				}
+				note := fmt.Sprintf("Reconciliation: stock %d, ledger %d", current[0].Stock, current[0].LedgerStock)
+				for _, c := range corrections {
+					locationID := c.LocationID
+					if err := s.LogStockChange(tx, d.ItemID, c.Change, "audit", "RECONCILE", userID, note, &locationID, nil); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			d.Fixed = true
+			fixed++
+		}
+	}
+
+	return &dtos.InventoryReconcileResponse{
+		CheckedItems:  checked,
+		Discrepancies: discrepancies,
+		Fixed:         fixed,
+	}, nil
+}
+
+// locationCorrection is the part of a reconcile entry posted at one location.
+type locationCorrection struct {
+	LocationID uint
+	Change     int
+}
+
+// reconcileLocations splits an item's stock/ledger difference over the locations
+// whose stock level differs from their own ledger entries. What remains, from
+// entries older than per-location stock, goes to the default location.
+func reconcileLocations(tx *gorm.DB, itemID uint, difference int) ([]locationCorrection, error) {
+	var levels []models.ItemStock
+	if err := tx.Where("item_id = ?", itemID).Order("location_id").Find(&levels).Error; err != nil {
+		return nil, err
+	}
+
+	var ledger []struct {
+		LocationID uint
+		Total      int
+	}
+	if err := tx.Model(&models.InventoryLog{}).
+		Select("location_id, COALESCE(SUM(`change`), 0) AS total").
+		Where("item_id = ? AND location_id IS NOT NULL", itemID).
+		Group("location_id").
+		Scan(&ledger).Error; err != nil {
+		return nil, err
+	}
+
+	// Stock minus ledger per location, a location with entries but no level holds none
+	diffs := make(map[uint]int)
+	var order []uint
+	for _, level := range levels {
+		diffs[level.LocationID] += level.Quantity
+		order = append(order, level.LocationID)
+	}
+	for _, l := range ledger {
+		if _, ok := diffs[l.LocationID]; !ok {
+			order = append(order, l.LocationID)
+		}
+		diffs[l.LocationID] -= l.Total
+	}
+
+	var corrections []locationCorrection
+	remaining := difference
+	for _, locationID := range order {
+		if diff := diffs[locationID]; diff != 0 {
+			corrections = append(corrections, locationCorrection{LocationID: locationID, Change: diff})
+			remaining -= diff
+		}
+	}
+	if remaining != 0 {
+		defaultID, err := resolveLocationID(tx, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		corrections = append(corrections, locationCorrection{LocationID: defaultID, Change: remaining})
+	}
+	return corrections, nil
+}
+
+func findStockDiscrepancies(db *gorm.DB, itemID *uint) ([]dtos.InventoryDiscrepancy, error) {
+	discrepancies := []dtos.InventoryDiscrepancy{}
+
+	query := db.Model(&models.Item{}).
+		Select("items.id AS item_id, items.name, items.stock, " +
+			"COALESCE(SUM(inventory_logs.`change`), 0) AS ledger_stock, " +
+			"COUNT(inventory_logs.id) AS entries").
+		Joins("LEFT JOIN inventory_logs ON inventory_logs.item_id = items.id").
+		Group("items.id, items.name, items.stock").
+		Having("items.stock <> COALESCE(SUM(inventory_logs.`change`), 0)").
+		Order("items.id")
+
+	if itemID != nil {
+		query = query.Where("items.id = ?", *itemID)
+	}
+
+	if err := query.Scan(&discrepancies).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range discrepancies {
+		discrepancies[i].Difference = discrepancies[i].Stock - discrepancies[i].LedgerStock
+	}
+
+	return discrepancies, nil
+}
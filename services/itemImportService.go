@@ -0,0 +1,504 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"kd-api/utils/permission"
+	"mime/multipart"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ItemImportService interface {
+	ImportItems(file *multipart.FileHeader, options dtos.ItemImportOptions, actor common.Actor, role string) (*dtos.ItemImportReport, error)
+}
+
+type itemImportService struct{}
+
+func NewItemImportService() ItemImportService {
+	return &itemImportService{}
+}
+
+// importRow is one data row, keyed by the lower-case column names of the header.
+// Columns missing from the file are absent from values.
+type importRow struct {
+	line   int
+	values map[string]string
+}
+
+func (r importRow) has(column string) bool {
+	value, ok := r.values[column]
+	return ok && value != ""
+}
+
+// importPlan is what a valid row will do. item holds the new values, old the
+// current ones for updates.
+type importPlan struct {
+	row    importRow
+	action string
+	item   models.Item
+	old    *models.Item
+}
+
+// ImportItems reads a CSV or XLSX file with the ExportItems columns. Every row is
+// validated first; in a dry run only the report is returned. Otherwise each valid row
+// is applied in its own transaction and rows with errors are skipped, so one bad row
+// does not block the rest of the file. The buy_price column is ignored for roles
+// that cannot see costs, as it is when they edit an item.
+func (s *itemImportService) ImportItems(file *multipart.FileHeader, options dtos.ItemImportOptions, actor common.Actor, role string) (*dtos.ItemImportReport, error) {
+	if options.Mode == "" {
+		options.Mode = "create"
+	}
+
+	rows, err := readImportRows(file)
+	if err != nil {
+		return nil, err
+	}
+	canSetCost := roleCan(role, permission.ItemsViewCost)
+
+	report := dtos.ItemImportReport{
+		DryRun: options.DryRun,
+		Mode:   options.Mode,
+		Total:  len(rows),
+		Rows:   make([]dtos.ItemImportRowResult, len(rows)),
+	}
+
+	// Keys already claimed by earlier rows, to catch duplicates inside the file
+	seenItems := make(map[uint]int)
+	seenNames := make(map[string]int)
+	seenSKUs := make(map[string]int)
+	seenBarcodes := make(map[string]int)
+
+	plans := make([]*importPlan, len(rows))
+	for i, row := range rows {
+		result := &report.Rows[i]
+		result.Row = row.line
+		result.Name = row.values["name"]
+
+		plan, rowErrors := planImportRow(row, options.Mode, canSetCost)
+		if plan != nil {
+			name := strings.ToLower(plan.item.Name)
+			if plan.old != nil {
+				if first, ok := seenItems[plan.old.ID]; ok {
+					rowErrors = append(rowErrors, fmt.Sprintf("same item as row %d", first))
+				}
+				seenItems[plan.old.ID] = row.line
+			}
+			if first, ok := seenNames[name]; ok {
+				rowErrors = append(rowErrors, fmt.Sprintf("name '%s' already used in row %d", plan.item.Name, first))
+			} else {
+				seenNames[name] = row.line
+			}
+			if plan.item.SKU != nil {
+				sku := strings.ToLower(*plan.item.SKU)
+				if first, ok := seenSKUs[sku]; ok {
+					rowErrors = append(rowErrors, fmt.Sprintf("sku '%s' already used in row %d", *plan.item.SKU, first))
+				} else {
+					seenSKUs[sku] = row.line
+				}
+			}
+			if plan.item.Barcode != nil {
+				if first, ok := seenBarcodes[*plan.item.Barcode]; ok {
+					rowErrors = append(rowErrors, fmt.Sprintf("barcode '%s' already used in row %d", *plan.item.Barcode, first))
+				} else {
+					seenBarcodes[*plan.item.Barcode] = row.line
+				}
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			result.Action = "error"
+			result.Errors = rowErrors
+			continue
+		}
+
+		plans[i] = plan
+		result.Action = plan.action
+		result.Name = plan.item.Name
+		if plan.old != nil {
+			id := plan.old.ID
+			result.ItemID = &id
+		}
+	}
+
+	if !options.DryRun {
+		locationID, err := resolveLocationID(config.DB, options.LocationID, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, plan := range plans {
+			if plan == nil || plan.action == "unchanged" {
+				continue
+			}
+
+			var itemID uint
+			err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+				var err error
+				if plan.action == "create" {
+					itemID, err = applyImportCreate(tx, plan, locationID, actor)
+				} else {
+					itemID, err = applyImportUpdate(tx, plan, locationID, actor)
+				}
+				return err
+			})
+
+			result := &report.Rows[i]
+			if err != nil {
+				result.Action = "error"
+				result.Errors = []string{err.Error()}
+				continue
+			}
+			result.ItemID = &itemID
+			refreshSearchIndex(itemID)
+		}
+	}
+
+	for _, result := range report.Rows {
+		switch result.Action {
+		case "create":
+			report.Created++
+		case "update":
+			report.Updated++
+		case "unchanged":
+			report.Unchanged++
+		default:
+			report.Failed++
+		}
+	}
+
+	return &report, nil
+}
+
+// readImportRows reads the header and data rows of a .csv or .xlsx file (first sheet).
+func readImportRows(file *multipart.FileHeader) ([]importRow, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var records [][]string
+	var lines []int // line of each record in the file
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".csv":
+		reader := csv.NewReader(src)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid CSV: %v", err)
+			}
+			line, _ := reader.FieldPos(0)
+			records = append(records, record)
+			lines = append(lines, line)
+		}
+	case ".xlsx":
+		workbook, err := excelize.OpenReader(src)
+		if err != nil {
+			return nil, errors.New("invalid XLSX file")
+		}
+		defer workbook.Close()
+
+		records, err = workbook.GetRows(workbook.GetSheetName(0))
+		if err != nil {
+			return nil, errors.New("invalid XLSX file")
+		}
+		for i := range records {
+			lines = append(lines, i+1)
+		}
+	default:
+		return nil, errors.New("unsupported file type, use .csv or .xlsx")
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("file is empty")
+	}
+
+	header := make([]string, len(records[0]))
+	columns := make(map[string]bool)
+	for i, name := range records[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		header[i] = name
+		columns[name] = true
+	}
+	if !columns["id"] && !columns["sku"] && !columns["name"] {
+		return nil, errors.New("file needs an id, sku or name column")
+	}
+
+	var rows []importRow
+	for n, record := range records[1:] {
+		row := importRow{line: lines[n+1], values: make(map[string]string, len(header))}
+		empty := true
+		for i, column := range header {
+			if column == "" {
+				continue
+			}
+			value := ""
+			if i < len(record) {
+				value = strings.TrimSpace(record[i])
+			}
+			if value != "" {
+				empty = false
+			}
+			row.values[column] = value
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// planImportRow validates a row and works out whether it creates, updates or leaves
+// an item unchanged. Matching goes by id, then sku, then name. Without canSetCost
+// the buy price is left as it is.
+func planImportRow(row importRow, mode string, canSetCost bool) (*importPlan, []string) {
+	var rowErrors []string
+
+	var existing *models.Item
+	switch {
+	case row.has("id"):
+		id, err := strconv.ParseUint(row.values["id"], 10, 64)
+		if err != nil {
+			return nil, []string{"id must be a whole number"}
+		}
+		var item models.Item
+		if err := config.DB.First(&item, id).Error; err != nil {
+			return nil, []string{fmt.Sprintf("item with id %d not found", id)}
+		}
+		existing = &item
+	case row.has("sku"):
+		var item models.Item
+		if err := config.DB.Where("sku = ?", row.values["sku"]).First(&item).Error; err == nil {
+			existing = &item
+		}
+	}
+	if existing == nil && row.has("name") {
+		var item models.Item
+		if err := config.DB.Where("name = ?", row.values["name"]).First(&item).Error; err == nil {
+			// A row with a different SKU is a new item that clashes by name
+			if !row.has("sku") || item.SKU == nil || *item.SKU == row.values["sku"] {
+				existing = &item
+			}
+		}
+	}
+
+	if existing != nil && mode != "upsert" {
+		return nil, []string{fmt.Sprintf("item already exists (id %d), use mode=upsert to update it", existing.ID)}
+	}
+
+	plan := &importPlan{row: row, action: "create"}
+	if existing != nil {
+		plan.action = "update"
+		plan.old = existing
+		plan.item = *existing
+	}
+	item := &plan.item
+
+	if row.has("name") {
+		item.Name = row.values["name"]
+	}
+	if item.Name == "" {
+		rowErrors = append(rowErrors, "name is required")
+	} else if len(item.Name) > 100 {
+		rowErrors = append(rowErrors, "name is longer than 100 characters")
+	}
+
+	if _, ok := row.values["description"]; ok {
+		item.Description = nil
+		if row.has("description") {
+			description := row.values["description"]
+			item.Description = &description
+		}
+	}
+	if _, ok := row.values["image_url"]; ok {
+		item.ImageURL = nil
+		if row.has("image_url") {
+			imageURL := row.values["image_url"]
+			item.ImageURL = &imageURL
+		}
+	}
+	if row.has("sku") {
+		sku := row.values["sku"]
+		item.SKU = trimmedOrNil(&sku)
+	}
+	if _, ok := row.values["category"]; ok {
+		category := row.values["category"]
+		item.Category = trimmedOrNil(&category)
+	}
+	if _, ok := row.values["barcode"]; ok {
+		barcode := row.values["barcode"]
+		item.Barcode = trimmedOrNil(&barcode)
+	}
+
+	if row.has("stock") {
+		stock, err := strconv.Atoi(row.values["stock"])
+		if err != nil || stock < 0 {
+			rowErrors = append(rowErrors, "stock must be a whole number, zero or more")
+		} else {
+			item.Stock = stock
+		}
+	}
+	if canSetCost && row.has("buy_price") {
+		buyPrice, err := strconv.ParseFloat(row.values["buy_price"], 64)
+		if err != nil || buyPrice < 0 {
+			rowErrors = append(rowErrors, "buy_price must be a number, zero or more")
+		} else {
+			item.BuyPrice = buyPrice
+		}
+	}
+	if row.has("price") {
+		price, err := strconv.ParseFloat(row.values["price"], 64)
+		if err != nil || price <= 0 {
+			rowErrors = append(rowErrors, "price must be a number above zero")
+		} else {
+			item.Price = price
+		}
+	} else if existing == nil {
+		rowErrors = append(rowErrors, "price is required")
+	}
+
+	var excludeID uint
+	if existing != nil {
+		excludeID = existing.ID
+	}
+	if item.Name != "" && (existing == nil || item.Name != existing.Name) {
+		var count int64
+		config.DB.Model(&models.Item{}).Where("name = ? AND id != ?", item.Name, excludeID).Count(&count)
+		if count > 0 {
+			rowErrors = append(rowErrors, "Item dengan nama ini sudah ada")
+		}
+	}
+	if skuTaken(config.DB, item.SKU, excludeID) {
+		rowErrors = append(rowErrors, "Item dengan SKU ini sudah ada")
+	}
+	if barcodeTaken(config.DB, item.Barcode, excludeID) {
+		rowErrors = append(rowErrors, "Item dengan barcode ini sudah ada")
+	}
+
+	// A kit's exported stock is derived from its components and is not imported
+	if existing != nil && existing.IsKit {
+		item.Stock = existing.Stock
+	}
+	if existing != nil && existing.IsSerialized && item.Stock != existing.Stock {
+		rowErrors = append(rowErrors, "stock of serialized items cannot be edited manually")
+	}
+	if len(rowErrors) > 0 {
+		return nil, rowErrors
+	}
+
+	if existing != nil && importUnchanged(*existing, *item) {
+		plan.action = "unchanged"
+	}
+	return plan, nil
+}
+
+func importUnchanged(current, imported models.Item) bool {
+	return current.Name == imported.Name &&
+		current.Stock == imported.Stock &&
+		current.BuyPrice == imported.BuyPrice &&
+		current.Price == imported.Price &&
+		equalStringPtr(current.SKU, imported.SKU) &&
+		equalStringPtr(current.Barcode, imported.Barcode) &&
+		equalStringPtr(current.Category, imported.Category) &&
+		equalStringPtr(current.Description, imported.Description) &&
+		equalStringPtr(current.ImageURL, imported.ImageURL)
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func applyImportCreate(tx *gorm.DB, plan *importPlan, locationID uint, actor common.Actor) (uint, error) {
+	item := plan.item
+	item.AverageCost = item.BuyPrice
+	if err := tx.Omit(clause.Associations).Create(&item).Error; err != nil {
+		return 0, err
+	}
+
+	description := fmt.Sprintf("Item '%s' created via file import (row %d)", item.Name, plan.row.line)
+	if err := log.CreateItemAuditLog(tx, "create", item.ID, nil, &item, actor, description); err != nil {
+		return 0, err
+	}
+
+	if err := recordPriceChange(tx, models.Item{}, item, "import", nil, actor.UserID); err != nil {
+		return 0, err
+	}
+
+	if err := NewCostingService().RecordOpeningStock(tx, item.ID, item.Stock, item.BuyPrice, "IMPORT"); err != nil {
+		return 0, err
+	}
+
+	if item.Stock > 0 {
+		invService := NewInventoryService()
+		if _, err := invService.AdjustStock(tx, item.ID, locationID, item.Stock); err != nil {
+			return 0, err
+		}
+		if err := invService.LogStockChange(tx, item.ID, item.Stock, "restock", "IMPORT", actor.UserID, "Imported initial stock", &locationID, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	return item.ID, nil
+}
+
+func applyImportUpdate(tx *gorm.DB, plan *importPlan, locationID uint, actor common.Actor) (uint, error) {
+	// Re-read under lock, the item may have changed since the file was validated
+	var old models.Item
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&old, plan.old.ID).Error; err != nil {
+		return 0, errors.New("Item not found")
+	}
+
+	item := old
+	item.Name = plan.item.Name
+	item.SKU = plan.item.SKU
+	item.Barcode = plan.item.Barcode
+	item.Category = plan.item.Category
+	item.Description = plan.item.Description
+	item.ImageURL = plan.item.ImageURL
+	item.BuyPrice = plan.item.BuyPrice
+	item.Price = plan.item.Price
+
+	if err := tx.Omit("stock", clause.Associations).Save(&item).Error; err != nil {
+		return 0, err
+	}
+
+	if err := recordPriceChange(tx, old, item, "import", nil, actor.UserID); err != nil {
+		return 0, err
+	}
+
+	if change := plan.item.Stock - old.Stock; change != 0 {
+		applied, err := adjustItemStock(tx, old, change, locationID, "IMPORT", "Imported stock update", actor.UserID)
+		if err != nil {
+			return 0, err
+		}
+		item.Stock = old.Stock + applied
+	}
+
+	description := fmt.Sprintf("Item '%s' updated via file import (row %d)", item.Name, plan.row.line)
+	if err := log.CreateItemAuditLog(tx, "update", item.ID, &old, &item, actor, description); err != nil {
+		return 0, err
+	}
+
+	return item.ID, nil
+}
@@ -1,30 +1,38 @@
 package services
 
 import (
-	"bytes"
 	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"kd-api/config"
 	"kd-api/dtos"
 	"kd-api/models"
 	"kd-api/utils/common"
 	"kd-api/utils/log"
 	"kd-api/utils/pagination"
+	"kd-api/utils/permission"
 	"kd-api/utils/response"
+	"kd-api/utils/storage"
 	"strings"
 
+	"github.com/xuri/excelize/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ItemService interface {
 	GetItems(filter dtos.ItemFilter, role string) (*dtos.ItemListResponse, error)
 	GetItemByID(id string, role string) (interface{}, error)
-	CreateItem(input dtos.CreateItemInput, userID *uint, clientIP string, role string) (interface{}, error)
-	UpdateItem(id string, input dtos.UpdateItemInput, userID *uint, clientIP string, role string) (interface{}, error)
-	DeleteItem(id string, userID *uint, clientIP string) error
-	BulkCreateItems(inputs dtos.BulkCreateItemInput, userID *uint, clientIP string, role string) (interface{}, error)
-	ExportItems(role string) (*dtos.CSVExport, error)
+	CreateItem(input dtos.CreateItemInput, actor common.Actor, role string) (interface{}, error)
+	UpdateItem(id string, input dtos.UpdateItemInput, actor common.Actor, role string) (interface{}, error)
+	DeleteItem(id string, actor common.Actor) error
+	GetDeletedItems(filter dtos.ItemFilter) (*dtos.ItemListResponse, error)
+	RestoreItem(id string, actor common.Actor, role string) (interface{}, error)
+	PurgeItem(id string, actor common.Actor) error
+	BulkCreateItems(inputs dtos.BulkCreateItemInput, actor common.Actor, role string) (interface{}, error)
+	ExportItems(filter dtos.ItemFilter, format string, role string) (*dtos.ItemExport, error)
 }
 
 type itemService struct{}
@@ -46,12 +54,9 @@ func (s *itemService) GetItems(filter dtos.ItemFilter, role string) (*dtos.ItemL
 	var items []models.Item
 	var total int64
 
-	query := config.DB.Model(&models.Item{})
-
-	if filter.Name != "" {
-		for _, term := range strings.Fields(strings.ToLower(strings.TrimSpace(filter.Name))) {
-			query = query.Where("LOWER(name) LIKE ?", "%"+term+"%")
-		}
+	query, err := applyItemFilter(config.DB.Model(&models.Item{}), filter)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := query.Count(&total).Error; err != nil {
@@ -59,12 +64,18 @@ func (s *itemService) GetItems(filter dtos.ItemFilter, role string) (*dtos.ItemL
 	}
 
 	if err := query.
+		Preload("StockLevels.Location").
+		Preload("Components.Component").
 		Offset(p.Offset).
 		Limit(p.PageSize).
 		Find(&items).Error; err != nil {
 		return nil, err
 	}
 
+	if err := NewReservationService().ApplyAvailability(items); err != nil {
+		return nil, err
+	}
+
 	meta := dtos.PaginationMeta{
 		Page:       p.Page,
 		Limit:      p.PageSize,
@@ -73,39 +84,89 @@ func (s *itemService) GetItems(filter dtos.ItemFilter, role string) (*dtos.ItemL
 	}
 
 	return &dtos.ItemListResponse{
-		Data: response.FilterItemsForRole(items, role),
+		Data: response.FilterItems(items, roleCan(role, permission.ItemsViewCost)),
 		Meta: meta,
 	}, nil
 }
 
 func (s *itemService) GetItemByID(id string, role string) (interface{}, error) {
 	var item models.Item
-	if err := config.DB.First(&item, id).Error; err != nil {
+	if err := config.DB.Preload("StockLevels.Location").Preload("Components.Component").First(&item, id).Error; err != nil {
 		return nil, errors.New("Item not found")
 	}
-	return response.FilterItemForRole(item, role), nil
+
+	items := []models.Item{item}
+	if err := NewReservationService().ApplyAvailability(items); err != nil {
+		return nil, err
+	}
+	return response.FilterItem(items[0], roleCan(role, permission.ItemsViewCost)), nil
 }
 
-func (s *itemService) CreateItem(input dtos.CreateItemInput, userID *uint, clientIP string, role string) (interface{}, error) {
+func (s *itemService) CreateItem(input dtos.CreateItemInput, actor common.Actor, role string) (interface{}, error) {
 	var existing models.Item
 	if err := config.DB.Where("name = ?", input.Name).First(&existing).Error; err == nil {
 		return nil, errors.New("Item dengan nama ini sudah ada")
 	}
 
+	sku := trimmedOrNil(input.SKU)
+	if skuTaken(config.DB, sku, 0) {
+		return nil, errors.New("Item dengan SKU ini sudah ada")
+	}
+
+	barcode := trimmedOrNil(input.Barcode)
+	if barcodeTaken(config.DB, barcode, 0) {
+		return nil, errors.New("Item dengan barcode ini sudah ada")
+	}
+
 	item := models.Item{
 		Name:        input.Name,
+		SKU:         sku,
+		Barcode:     barcode,
 		Description: input.Description,
+		Category:    trimmedOrNil(input.Category),
 		Stock:       input.Stock,
 		BuyPrice:    input.BuyPrice,
 		Price:       input.Price,
+		AverageCost: input.BuyPrice,
 		ImageURL:    input.ImageURL,
+
+		SupplierID:   input.SupplierID,
+		MinStock:     input.MinStock,
+		ReorderPoint: input.ReorderPoint,
+		ReorderQty:   input.ReorderQty,
+
+		IsSerialized:   input.IsSerialized,
+		WarrantyMonths: input.WarrantyMonths,
+	}
+
+	if item.IsSerialized && len(input.Serials) != item.Stock {
+		return nil, errors.New("serialized items need one serial number per unit of stock")
+	}
+	if len(input.Components) > 0 && (item.Stock != 0 || item.IsSerialized) {
+		return nil, errors.New("kits hold no stock of their own and cannot be serialized")
 	}
 
-	err := config.DB.Transaction(func(tx *gorm.DB) error {
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&item).Error; err != nil {
 			return err
 		}
 
+		if err := recordPriceChange(tx, models.Item{}, item, "create", nil, actor.UserID); err != nil {
+			return err
+		}
+
+		if item.IsSerialized && len(input.Serials) > 0 {
+			if err := NewSerialService().RegisterSerials(tx, item.ID, input.Serials, nil); err != nil {
+				return err
+			}
+		}
+
+		if len(input.Components) > 0 {
+			if err := NewKitService().SetComponents(tx, &item, input.Components); err != nil {
+				return err
+			}
+		}
+
 		description := fmt.Sprintf("Item '%s' created", item.Name)
 		if err := log.CreateItemAuditLog(
 			tx,
@@ -113,17 +174,28 @@ func (s *itemService) CreateItem(input dtos.CreateItemInput, userID *uint, clien
 			item.ID,
 			nil,
 			&item,
-			userID,
-			clientIP,
+			actor,
 			description,
 		); err != nil {
 			return err
 		}
 
+		if err := NewCostingService().RecordOpeningStock(tx, item.ID, item.Stock, item.BuyPrice, "INITIAL"); err != nil {
+			return err
+		}
+
 		// Inventory Log (Initial Stock)
 		if item.Stock > 0 {
+			locationID, err := resolveLocationID(tx, input.LocationID, nil)
+			if err != nil {
+				return err
+			}
+
 			invService := NewInventoryService()
-			if err := invService.LogStockChange(tx, item.ID, item.Stock, "restock", "INITIAL", userID, "Initial stock"); err != nil {
+			if _, err := invService.AdjustStock(tx, item.ID, locationID, item.Stock); err != nil {
+				return err
+			}
+			if err := invService.LogStockChange(tx, item.ID, item.Stock, "restock", "INITIAL", actor.UserID, "Initial stock", &locationID, nil); err != nil {
 				return err
 			}
 		}
@@ -135,10 +207,12 @@ func (s *itemService) CreateItem(input dtos.CreateItemInput, userID *uint, clien
 		return nil, err
 	}
 
-	return response.FilterItemForRole(item, role), nil
+	refreshSearchIndex(item.ID)
+
+	return response.FilterItem(item, roleCan(role, permission.ItemsViewCost)), nil
 }
 
-func (s *itemService) UpdateItem(id string, input dtos.UpdateItemInput, userID *uint, clientIP string, role string) (interface{}, error) {
+func (s *itemService) UpdateItem(id string, input dtos.UpdateItemInput, actor common.Actor, role string) (interface{}, error) {
 	var oldItem models.Item
 	if err := config.DB.First(&oldItem, id).Error; err != nil {
 		return nil, errors.New("Item not found")
@@ -150,20 +224,105 @@ func (s *itemService) UpdateItem(id string, input dtos.UpdateItemInput, userID *
 		return nil, errors.New("Item dengan nama ini sudah ada")
 	}
 
+	if input.SKU != nil && skuTaken(config.DB, trimmedOrNil(input.SKU), oldItem.ID) {
+		return nil, errors.New("Item dengan SKU ini sudah ada")
+	}
+	if input.Barcode != nil && barcodeTaken(config.DB, trimmedOrNil(input.Barcode), oldItem.ID) {
+		return nil, errors.New("Item dengan barcode ini sudah ada")
+	}
+
+	stockChange := 0
+	if input.Stock != nil {
+		stockChange = *input.Stock - oldItem.Stock
+	}
+	if stockChange != 0 && !oldItem.IsKit && !roleCan(role, permission.ItemsAdjustStock) {
+		return nil, errors.New("You do not have permission to change stock")
+	}
+
 	oldCopy := oldItem
 
-	err := config.DB.Transaction(func(tx *gorm.DB) error {
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
 		oldItem.Name = input.Name
 		oldItem.Description = input.Description
-		oldItem.Stock = input.Stock
-		oldItem.BuyPrice = input.BuyPrice
+		// Users who cannot see the buy price cannot overwrite it either
+		if roleCan(role, permission.ItemsViewCost) {
+			oldItem.BuyPrice = input.BuyPrice
+		}
 		oldItem.Price = input.Price
 		oldItem.ImageURL = input.ImageURL
 
-		if err := tx.Save(&oldItem).Error; err != nil {
+		if input.SKU != nil {
+			oldItem.SKU = trimmedOrNil(input.SKU)
+		}
+		if input.Barcode != nil {
+			oldItem.Barcode = trimmedOrNil(input.Barcode)
+		}
+		if input.Category != nil {
+			oldItem.Category = trimmedOrNil(input.Category)
+		}
+		if input.SupplierID != nil {
+			oldItem.SupplierID = input.SupplierID
+			if *input.SupplierID == 0 {
+				oldItem.SupplierID = nil
+			}
+		}
+		if input.MinStock != nil {
+			oldItem.MinStock = *input.MinStock
+		}
+		if input.ReorderPoint != nil {
+			oldItem.ReorderPoint = *input.ReorderPoint
+		}
+		if input.ReorderQty != nil {
+			oldItem.ReorderQty = *input.ReorderQty
+		}
+		if input.IsSerialized != nil && *input.IsSerialized != oldCopy.IsSerialized {
+			// Units on hand would otherwise have no serials, or serials nothing tracking them
+			if oldCopy.Stock != 0 {
+				return errors.New("serial tracking can only be switched while the item has no stock")
+			}
+			oldItem.IsSerialized = *input.IsSerialized
+		}
+		if input.WarrantyMonths != nil {
+			oldItem.WarrantyMonths = *input.WarrantyMonths
+		}
+
+		// Serialized stock only moves with known units, through receipts and sales
+		if oldItem.IsSerialized && stockChange != 0 {
+			return errors.New("stock of serialized items cannot be edited manually")
+		}
+
+		if input.Components != nil {
+			if len(*input.Components) > 0 && oldItem.IsSerialized {
+				return errors.New("kits hold no stock of their own and cannot be serialized")
+			}
+			if err := NewKitService().SetComponents(tx, &oldItem, *input.Components); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Omit("stock", "Components").Save(&oldItem).Error; err != nil {
+			return err
+		}
+
+		if err := recordPriceChange(tx, oldCopy, oldItem, "manual", nil, actor.UserID); err != nil {
 			return err
 		}
 
+		// Inventory Log (Stock Adjustment). A kit's stock shown on read comes from
+		// its components and is not edited here.
+		if stockChange != 0 && !oldItem.IsKit {
+			locationID, err := resolveLocationID(tx, input.LocationID, nil)
+			if err != nil {
+				return err
+			}
+
+			applied, err := adjustItemStock(tx, oldCopy, stockChange, locationID, "MANUAL", "Manual stock update", actor.UserID)
+			if err != nil {
+				return err
+			}
+			oldItem.Stock = oldCopy.Stock + applied
+		}
+
 		description := fmt.Sprintf("Item '%s' updated", oldItem.Name)
 		if err := log.CreateItemAuditLog(
 			tx,
@@ -171,22 +330,12 @@ func (s *itemService) UpdateItem(id string, input dtos.UpdateItemInput, userID *
 			oldItem.ID,
 			&oldCopy,
 			&oldItem,
-			userID,
-			clientIP,
+			actor,
 			description,
 		); err != nil {
 			return err
 		}
 
-		// Inventory Log (Stock Adjustment)
-		stockChange := oldItem.Stock - oldCopy.Stock
-		if stockChange != 0 {
-			invService := NewInventoryService()
-			if err := invService.LogStockChange(tx, oldItem.ID, stockChange, "adjustment", "MANUAL", userID, "Manual stock update"); err != nil {
-				return err
-			}
-		}
-
 		return nil
 	})
 
@@ -194,10 +343,167 @@ func (s *itemService) UpdateItem(id string, input dtos.UpdateItemInput, userID *
 		return nil, err
 	}
 
-	return response.FilterItemForRole(oldItem, role), nil
+	refreshSearchIndex(oldItem.ID)
+
+	return response.FilterItem(oldItem, roleCan(role, permission.ItemsViewCost)), nil
+}
+
+// adjustItemStock applies a manual stock correction at the location and writes the
+// "adjustment" ledger entries. Added units are costed at the current average, removed
+// units leave the cost layers and lots like a sale would. Returns the applied change,
+// which for removals stops at the stock on hand.
+func adjustItemStock(tx *gorm.DB, item models.Item, change int, locationID uint, refID string, note string, userID *uint) (int, error) {
+	costing := NewCostingService()
+	// The average is taken over the stock on hand before the units are added
+	if change > 0 {
+		if err := costing.RecordReceipt(tx, item.ID, change, item.AverageCost, refID); err != nil {
+			return 0, err
+		}
+	}
+
+	applied, err := NewInventoryService().AdjustStock(tx, item.ID, locationID, change)
+	if err != nil {
+		return 0, err
+	}
+
+	var allocations []models.TransactionItemLot
+	if applied < 0 {
+		// Only the units actually removed leave the cost layers
+		if _, err := costing.CostOfSale(tx, item.ID, -applied); err != nil {
+			return 0, err
+		}
+		allocations, err = NewLotService().ConsumeLots(tx, item.ID, locationID, -applied, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err := logStockChangeByLot(tx, item.ID, applied, allocations, "adjustment", refID, userID, note, &locationID); err != nil {
+		return 0, err
+	}
+	return applied, nil
+}
+
+// trimmedOrNil trims an optional text field such as the SKU, blanks are stored as NULL.
+func trimmedOrNil(sku *string) *string {
+	if sku == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*sku)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// Sortable item fields, keyed by the name used in the sort parameter
+var itemSortFields = map[string]string{
+	"id":         "items.id",
+	"name":       "items.name",
+	"category":   "items.category",
+	"stock":      "items.stock",
+	"price":      "items.price",
+	"created_at": "items.created_at",
+	"updated_at": "items.updated_at",
+
+	// Units sold in completed transactions
+	"best_selling": `(SELECT COALESCE(SUM(ti.quantity), 0) FROM transaction_items ti
+		JOIN transactions t ON t.id = ti.transaction_id
+		WHERE ti.item_id = items.id AND t.status = 'completed')`,
+}
+
+// inStockCondition matches items with stock to sell. Kits hold no stock of their own
+// and are in stock when every component is.
+const inStockCondition = `(items.is_kit = false AND items.stock > 0) OR (items.is_kit = true AND NOT EXISTS (
+	SELECT 1 FROM kit_components kc JOIN items c ON c.id = kc.component_id
+	WHERE kc.kit_id = items.id AND c.stock < kc.quantity))`
+
+// applyItemFilter adds the filters and the sort order shared by the item lists (admin
+// and public) and the exports. A search matches names, descriptions, categories, SKUs
+// and barcodes through the search index and, without an explicit sort, lists the best
+// matches first.
+func applyItemFilter(query *gorm.DB, filter dtos.ItemFilter) (*gorm.DB, error) {
+	var rankedIDs []uint
+	if strings.TrimSpace(filter.Name) != "" {
+		ids, err := searchItemIDs(filter.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return query.Where("1 = 0"), nil
+		}
+		query = query.Where("items.id IN ?", ids)
+		rankedIDs = ids
+	}
+	if filter.Category != "" {
+		query = query.Where("items.category = ?", filter.Category)
+	}
+	if filter.LowStock {
+		lowStockSQL, lowStockArgs := lowStockCondition()
+		query = query.Where(lowStockSQL, lowStockArgs...)
+	}
+	if filter.InStock {
+		query = query.Where(inStockCondition)
+	}
+	if filter.MinPrice != nil {
+		query = query.Where("items.price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		query = query.Where("items.price <= ?", *filter.MaxPrice)
+	}
+	if filter.MinStock != nil {
+		query = query.Where("items.stock >= ?", *filter.MinStock)
+	}
+	if filter.MaxStock != nil {
+		query = query.Where("items.stock <= ?", *filter.MaxStock)
+	}
+	if filter.UpdatedSince != nil {
+		query = query.Where("items.updated_at >= ?", *filter.UpdatedSince)
+	}
+
+	sort := strings.TrimSpace(filter.Sort)
+	if sort == "" && rankedIDs != nil {
+		return query.Clauses(clause.OrderBy{
+			Expression: clause.Expr{SQL: "FIELD(items.id, ?)", Vars: []any{rankedIDs}, WithoutParentheses: true},
+		}), nil
+	}
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		direction := "ASC"
+		if strings.HasPrefix(field, "-") {
+			direction = "DESC"
+			field = field[1:]
+		}
+		column, ok := itemSortFields[field]
+		if !ok {
+			return nil, errors.New("invalid sort field")
+		}
+		query = query.Order(column + " " + direction)
+	}
+	return query.Order("items.id"), nil
+}
+
+func skuTaken(db *gorm.DB, sku *string, excludeID uint) bool {
+	if sku == nil {
+		return false
+	}
+	var count int64
+	db.Model(&models.Item{}).Where("sku = ? AND id != ?", *sku, excludeID).Count(&count)
+	return count > 0
 }
 
-func (s *itemService) DeleteItem(id string, userID *uint, clientIP string) error {
+func barcodeTaken(db *gorm.DB, barcode *string, excludeID uint) bool {
+	if barcode == nil {
+		return false
+	}
+	var count int64
+	db.Model(&models.Item{}).Where("barcode = ? AND id != ?", *barcode, excludeID).Count(&count)
+	return count > 0
+}
+
+func (s *itemService) DeleteItem(id string, actor common.Actor) error {
 	var item models.Item
 	if err := config.DB.First(&item, id).Error; err != nil {
 		return errors.New("Item not found")
@@ -205,7 +511,7 @@ func (s *itemService) DeleteItem(id string, userID *uint, clientIP string) error
 
 	itemCopy := item
 
-	return config.DB.Transaction(func(tx *gorm.DB) error {
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Delete(&item).Error; err != nil {
 			return err
 		}
@@ -217,17 +523,192 @@ func (s *itemService) DeleteItem(id string, userID *uint, clientIP string) error
 			itemCopy.ID,
 			&itemCopy,
 			nil,
-			userID,
-			clientIP,
+			actor,
+			description,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	refreshSearchIndex(itemCopy.ID)
+	return nil
+}
+
+// GetDeletedItems lists the recycle bin, most recently deleted first.
+func (s *itemService) GetDeletedItems(filter dtos.ItemFilter) (*dtos.ItemListResponse, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 {
+		filter.PageSize = 10
+	}
+
+	p := pagination.New(filter.Page, filter.PageSize)
+
+	query := config.DB.Unscoped().Model(&models.Item{}).Where("deleted_at IS NOT NULL")
+	for _, term := range strings.Fields(filter.Name) {
+		query = query.Where("name LIKE ?", "%"+term+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var items []models.Item
+	if err := query.Order("deleted_at DESC").
+		Offset(p.Offset).
+		Limit(p.PageSize).
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	data := make([]dtos.DeletedItemResponse, len(items))
+	for i, item := range items {
+		data[i] = dtos.DeletedItemResponse{
+			ID:        item.ID,
+			Name:      item.Name,
+			SKU:       item.SKU,
+			Category:  item.Category,
+			Price:     item.Price,
+			IsKit:     item.IsKit,
+			DeletedAt: item.DeletedAt.Time,
+		}
+	}
+
+	return &dtos.ItemListResponse{
+		Data: data,
+		Meta: dtos.PaginationMeta{
+			Page:       p.Page,
+			Limit:      p.PageSize,
+			Total:      total,
+			TotalPages: int((total + int64(p.PageSize) - 1) / int64(p.PageSize)),
+		},
+	}, nil
+}
+
+func (s *itemService) RestoreItem(id string, actor common.Actor, role string) (interface{}, error) {
+	var item models.Item
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&item, id).Error; err != nil {
+		return nil, errors.New("Deleted item not found")
+	}
+
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.Item{}).Where("id = ?", item.ID).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Item '%s' restored", item.Name)
+		return log.CreateItemAuditLog(
+			tx,
+			"restore",
+			item.ID,
+			nil,
+			&item,
+			actor,
 			description,
 		)
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshSearchIndex(item.ID)
+
+	return s.GetItemByID(fmt.Sprint(item.ID), role)
 }
 
-func (s *itemService) BulkCreateItems(inputs dtos.BulkCreateItemInput, userID *uint, clientIP string, role string) (interface{}, error) {
+// Tables that keep an item's business history. A purge is refused while any of them
+// still points at the item, so past documents never lose their lines.
+var itemHistoryReferences = []struct {
+	model any
+	label string
+	where string
+}{
+	{&models.TransactionItem{}, "transactions", "item_id = ?"},
+	{&models.GoodsReceiptItem{}, "goods receipts", "item_id = ?"},
+	{&models.PurchaseOrderItem{}, "purchase orders", "item_id = ?"},
+	{&models.StockTransferItem{}, "stock transfers", "item_id = ?"},
+	{&models.KitComponent{}, "kits", "component_id = ?"},
+}
+
+// Rows that only exist for the item and are removed with it.
+var itemOwnedModels = []any{
+	&models.ItemStock{},
+	&models.InventoryLog{},
+	&models.CostLayer{},
+	&models.Lot{},
+	&models.PriceHistory{},
+	&models.ScheduledPriceChange{},
+	&models.StockReservation{},
+	&models.SerialNumber{},
+}
+
+// PurgeItem permanently removes an item from the recycle bin together with its
+// stock, ledger and price records. Items used by any document cannot be purged.
+func (s *itemService) PurgeItem(id string, actor common.Actor) error {
+	var item models.Item
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&item, id).Error; err != nil {
+		return errors.New("Deleted item not found")
+	}
+
+	for _, ref := range itemHistoryReferences {
+		var count int64
+		if err := config.DB.Model(ref.model).Where(ref.where, item.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("item is used in %s and cannot be purged", ref.label)
+		}
+	}
+
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		for _, model := range itemOwnedModels {
+			if err := tx.Unscoped().Where("item_id = ?", item.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("kit_id = ?", item.ID).Delete(&models.KitComponent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&models.Item{}, item.ID).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Item '%s' permanently deleted", item.Name)
+		return log.CreateItemAuditLog(
+			tx,
+			"purge",
+			item.ID,
+			&item,
+			nil,
+			actor,
+			description,
+		)
+	})
+	if err != nil {
+		return err
+	}
+
+	// The files are unreachable once the item is gone, a failed delete only leaves garbage behind
+	store := storage.FromEnv()
+	if item.ImageKey != nil {
+		store.Delete(*item.ImageKey)
+	}
+	if item.ThumbnailKey != nil {
+		store.Delete(*item.ThumbnailKey)
+	}
+	return nil
+}
+
+func (s *itemService) BulkCreateItems(inputs dtos.BulkCreateItemInput, actor common.Actor, role string) (interface{}, error) {
 	items := []models.Item(inputs)
 
 	for i := range items {
+		items[i].AverageCost = items[i].BuyPrice
+
 		if items[i].Description != nil && *items[i].Description == "" {
 			items[i].Description = nil
 		}
@@ -236,8 +717,13 @@ func (s *itemService) BulkCreateItems(inputs dtos.BulkCreateItemInput, userID *u
 		}
 	}
 
-	err := config.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(&items).Error; err != nil {
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&items).Error; err != nil {
+			return err
+		}
+
+		locationID, err := resolveLocationID(tx, nil, nil)
+		if err != nil {
 			return err
 		}
 
@@ -249,17 +735,27 @@ func (s *itemService) BulkCreateItems(inputs dtos.BulkCreateItemInput, userID *u
 				item.ID,
 				nil,
 				&item,
-				userID,
-				clientIP,
+				actor,
 				description,
 			); err != nil {
 				return err
 			}
 
+			if err := recordPriceChange(tx, models.Item{}, item, "create", nil, actor.UserID); err != nil {
+				return err
+			}
+
+			if err := NewCostingService().RecordOpeningStock(tx, item.ID, item.Stock, item.BuyPrice, "BULK_IMPORT"); err != nil {
+				return err
+			}
+
 			// Inventory Log
 			if item.Stock > 0 {
 				invService := NewInventoryService()
-				if err := invService.LogStockChange(tx, item.ID, item.Stock, "restock", "BULK_IMPORT", userID, "Bulk import initial stock"); err != nil {
+				if _, err := invService.AdjustStock(tx, item.ID, locationID, item.Stock); err != nil {
+					return err
+				}
+				if err := invService.LogStockChange(tx, item.ID, item.Stock, "restock", "BULK_IMPORT", actor.UserID, "Bulk import initial stock", &locationID, nil); err != nil {
 					return err
 				}
 			}
@@ -271,61 +767,229 @@ func (s *itemService) BulkCreateItems(inputs dtos.BulkCreateItemInput, userID *u
 		return nil, err
 	}
 
-	return response.FilterItemsForRole(items, role), nil
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	refreshSearchIndex(ids...)
+
+	return response.FilterItems(items, roleCan(role, permission.ItemsViewCost)), nil
 }
 
-func (s *itemService) ExportItems(role string) (*dtos.CSVExport, error) {
-	var items []models.Item
-	if err := config.DB.Find(&items).Error; err != nil {
+// itemExportColumn is one export column. value returns a string, int, float64 or nil.
+type itemExportColumn struct {
+	header string
+	value  func(item models.Item) any
+}
+
+func optionalText(value *string) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// itemExportColumns keeps the original CSV columns first so exported files can be
+// imported again. Buy prices and stock valuation need the items.view_cost permission.
+func itemExportColumns(showCost bool) []itemExportColumn {
+	columns := []itemExportColumn{
+		{"id", func(item models.Item) any { return item.ID }},
+		{"name", func(item models.Item) any { return item.Name }},
+		{"description", func(item models.Item) any { return optionalText(item.Description) }},
+		{"stock", func(item models.Item) any { return item.Stock }},
+	}
+	if showCost {
+		columns = append(columns, itemExportColumn{"buy_price", func(item models.Item) any { return item.BuyPrice }})
+	}
+	columns = append(columns,
+		itemExportColumn{"price", func(item models.Item) any { return item.Price }},
+		itemExportColumn{"image_url", func(item models.Item) any { return optionalText(item.ImageURL) }},
+		itemExportColumn{"sku", func(item models.Item) any { return optionalText(item.SKU) }},
+		itemExportColumn{"barcode", func(item models.Item) any { return optionalText(item.Barcode) }},
+		itemExportColumn{"category", func(item models.Item) any { return optionalText(item.Category) }},
+	)
+	if showCost {
+		columns = append(columns,
+			itemExportColumn{"stock_value", func(item models.Item) any { return float64(item.Stock) * item.BuyPrice }},
+			itemExportColumn{"retail_value", func(item models.Item) any { return float64(item.Stock) * item.Price }},
+		)
+	}
+	return columns
+}
+
+// ExportItems validates the filter and returns an export whose Write streams the
+// matching items in chunks, so large catalogs are never held in memory at once.
+// XLSX rows go through excelize's stream writer, which spills to a temporary file.
+func (s *itemService) ExportItems(filter dtos.ItemFilter, format string, role string) (*dtos.ItemExport, error) {
+	query, err := applyItemFilter(config.DB.Model(&models.Item{}), filter)
+	if err != nil {
 		return nil, err
 	}
 
-	var buffer bytes.Buffer
-	writer := csv.NewWriter(&buffer)
+	columns := itemExportColumns(roleCan(role, permission.ItemsViewCost))
+	each := func(fn func(values []any) error) error {
+		return eachItemChunk(query, 500, func(items []models.Item) error {
+			for _, item := range items {
+				values := make([]any, len(columns))
+				for i, column := range columns {
+					values[i] = column.value(item)
+				}
+				if err := fn(values); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = column.header
+	}
+
+	switch format {
+	case "", "csv":
+		return &dtos.ItemExport{
+			FileName:    "items.csv",
+			ContentType: "text/csv",
+			Write: func(w io.Writer) error {
+				writer := csv.NewWriter(w)
+				writer.Write(headers)
+				err := each(func(values []any) error {
+					return writer.Write(formatCSVValues(values))
+				})
+				writer.Flush()
+				if err != nil {
+					return err
+				}
+				return writer.Error()
+			},
+		}, nil
+	case "json":
+		return &dtos.ItemExport{
+			FileName:    "items.json",
+			ContentType: "application/json",
+			Write: func(w io.Writer) error {
+				separator := "["
+				err := each(func(values []any) error {
+					row := make(map[string]any, len(values))
+					for i, value := range values {
+						row[headers[i]] = value
+					}
+					data, err := json.Marshal(row)
+					if err != nil {
+						return err
+					}
+					if _, err := io.WriteString(w, separator+"\n"); err != nil {
+						return err
+					}
+					separator = ","
+					_, err = w.Write(data)
+					return err
+				})
+				if err != nil {
+					return err
+				}
+				if separator == "[" {
+					_, err = io.WriteString(w, "[]\n")
+				} else {
+					_, err = io.WriteString(w, "\n]\n")
+				}
+				return err
+			},
+		}, nil
+	case "xlsx":
+		return &dtos.ItemExport{
+			FileName:    "items.xlsx",
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Write: func(w io.Writer) error {
+				workbook := excelize.NewFile()
+				defer workbook.Close()
+
+				stream, err := workbook.NewStreamWriter("Sheet1")
+				if err != nil {
+					return err
+				}
+
+				header := make([]any, len(headers))
+				for i, h := range headers {
+					header[i] = h
+				}
+				if err := stream.SetRow("A1", header); err != nil {
+					return err
+				}
 
-	writer.Write(getCSVHeaders(role))
-	for _, item := range items {
-		writer.Write(formatItemCSVRow(item, role))
+				rowNumber := 1
+				err = each(func(values []any) error {
+					rowNumber++
+					cell, _ := excelize.CoordinatesToCellName(1, rowNumber)
+					return stream.SetRow(cell, values)
+				})
+				if err != nil {
+					return err
+				}
+				if err := stream.Flush(); err != nil {
+					return err
+				}
+				return workbook.Write(w)
+			},
+		}, nil
 	}
-	writer.Flush()
 
-	return &dtos.CSVExport{
-		FileName: "items.csv",
-		Content:  buffer.Bytes(),
-	}, nil
+	return nil, errors.New("unsupported export format, use csv, xlsx or json")
 }
 
+// eachItemChunk streams the query's items from the database and hands them over in
+// chunks, with reservations and kit stock applied.
+func eachItemChunk(query *gorm.DB, size int, fn func(items []models.Item) error) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
-// Helper functions for CSV (internal to service)
-func formatItemCSVRow(item models.Item, role string) []string {
-	desc := common.GetStringValue(item.Description)
-	img := common.GetStringValue(item.ImageURL)
-
-	if role == "cashier" {
-		return []string{
-			fmt.Sprintf("%d", item.ID),
-			item.Name,
-			desc,
-			fmt.Sprintf("%d", item.Stock),
-			fmt.Sprintf("%.2f", item.Price),
-			img,
+	chunk := make([]models.Item, 0, size)
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
 		}
+		if err := NewReservationService().ApplyAvailability(chunk); err != nil {
+			return err
+		}
+		err := fn(chunk)
+		chunk = chunk[:0]
+		return err
 	}
 
-	return []string{
-		fmt.Sprintf("%d", item.ID),
-		item.Name,
-		desc,
-		fmt.Sprintf("%d", item.Stock),
-		fmt.Sprintf("%.2f", item.BuyPrice),
-		fmt.Sprintf("%.2f", item.Price),
-		img,
+	for rows.Next() {
+		var item models.Item
+		if err := config.DB.ScanRows(rows, &item); err != nil {
+			return err
+		}
+		chunk = append(chunk, item)
+		if len(chunk) == size {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
 	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return flush()
 }
 
-func getCSVHeaders(role string) []string {
-	if role == "cashier" {
-		return []string{"id", "name", "description", "stock", "price", "image_url"}
+func formatCSVValues(values []any) []string {
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil:
+			record[i] = ""
+		case float64:
+			record[i] = fmt.Sprintf("%.2f", v)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
 	}
-	return []string{"id", "name", "description", "stock", "buy_price", "price", "image_url"}
+	return record
 }
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/dtos"
+	"kd-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type KitService interface {
+	SetComponents(tx *gorm.DB, kit *models.Item, inputs []dtos.KitComponentInput) error
+	ApplyKitAvailability(tx *gorm.DB, items []models.Item) error
+}
+
+type kitService struct{}
+
+func NewKitService() KitService {
+	return &kitService{}
+}
+
+// stockLine is a quantity of an item that actually holds stock. A kit expands into
+// one line per component.
+type stockLine struct {
+	Item     models.Item
+	Quantity int
+	UnitCost float64
+}
+
+// expandKit returns the stock lines behind selling quantity units of item.
+func expandKit(tx *gorm.DB, item models.Item, quantity int) ([]stockLine, error) {
+	if !item.IsKit {
+		return []stockLine{{Item: item, Quantity: quantity}}, nil
+	}
+
+	var components []models.KitComponent
+	if err := tx.Preload("Component").Where("kit_id = ?", item.ID).Find(&components).Error; err != nil {
+		return nil, err
+	}
+	if len(components) == 0 {
+		return nil, fmt.Errorf("kit '%s' has no components", item.Name)
+	}
+
+	lines := make([]stockLine, len(components))
+	for i, component := range components {
+		lines[i] = stockLine{Item: component.Component, Quantity: quantity * component.Quantity}
+	}
+	return lines, nil
+}
+
+// soldStockLines returns the stock lines a sold transaction item took, at the cost
+// they were sold for. Kits use the components recorded at the sale; kits sold
+// before those were kept fall back to the current components at their average cost.
+func soldStockLines(tx *gorm.DB, item models.Item, tItem models.TransactionItem) ([]stockLine, error) {
+	var components []models.TransactionItemComponent
+	if err := tx.Preload("Item").Where("transaction_item_id = ?", tItem.ID).Find(&components).Error; err != nil {
+		return nil, err
+	}
+	if len(components) == 0 {
+		lines, err := expandKit(tx, item, tItem.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		for i := range lines {
+			lines[i].UnitCost = tItem.UnitCost
+			if item.IsKit {
+				lines[i].UnitCost = lines[i].Item.AverageCost
+			}
+		}
+		return lines, nil
+	}
+
+	var lines []stockLine
+	for _, component := range components {
+		// Components deleted since the sale do not get stock back
+		if component.Item.ID == 0 {
+			continue
+		}
+		lines = append(lines, stockLine{Item: component.Item, Quantity: component.Quantity, UnitCost: component.UnitCost})
+	}
+	return lines, nil
+}
+
+// SetComponents replaces the kit's components. Components must be plain stocked
+// items: no kits inside kits and no serialized units.
+func (s *kitService) SetComponents(tx *gorm.DB, kit *models.Item, inputs []dtos.KitComponentInput) error {
+	seen := make(map[uint]bool, len(inputs))
+	for _, input := range inputs {
+		if input.ItemID == kit.ID {
+			return errors.New("a kit cannot contain itself")
+		}
+		if seen[input.ItemID] {
+			return fmt.Errorf("item %d listed twice in kit", input.ItemID)
+		}
+		seen[input.ItemID] = true
+
+		var component models.Item
+		if err := tx.First(&component, input.ItemID).Error; err != nil {
+			return fmt.Errorf("item %d not found", input.ItemID)
+		}
+		if component.IsKit {
+			return fmt.Errorf("item '%s' is a kit and cannot be a component", component.Name)
+		}
+		if component.IsSerialized {
+			return fmt.Errorf("item '%s' is serialized and cannot be a component", component.Name)
+		}
+	}
+
+	isKit := len(inputs) > 0
+	if isKit && kit.Stock != 0 {
+		return errors.New("move the item's stock out before turning it into a kit")
+	}
+
+	if err := tx.Where("kit_id = ?", kit.ID).Delete(&models.KitComponent{}).Error; err != nil {
+		return err
+	}
+
+	components := make([]models.KitComponent, len(inputs))
+	for i, input := range inputs {
+		components[i] = models.KitComponent{KitID: kit.ID, ComponentID: input.ItemID, Quantity: input.Quantity}
+	}
+	if isKit {
+		if err := tx.Omit(clause.Associations).Create(&components).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&models.Item{}).Where("id = ?", kit.ID).Update("is_kit", isKit).Error; err != nil {
+		return err
+	}
+	kit.IsKit = isKit
+	kit.Components = components
+	return nil
+}
+
+// ApplyKitAvailability derives Stock, Reserved and Available of kits from their
+// components: the number of complete kits that can be put together. Items that
+// are not kits are left untouched.
+func (s *kitService) ApplyKitAvailability(tx *gorm.DB, items []models.Item) error {
+	var kitIDs []uint
+	for _, item := range items {
+		if item.IsKit {
+			kitIDs = append(kitIDs, item.ID)
+		}
+	}
+	if len(kitIDs) == 0 {
+		return nil
+	}
+
+	var components []models.KitComponent
+	if err := tx.Preload("Component").Where("kit_id IN ?", kitIDs).Find(&components).Error; err != nil {
+		return err
+	}
+
+	parts := make([]models.Item, len(components))
+	for i, component := range components {
+		parts[i] = component.Component
+	}
+	if err := NewReservationService().ApplyAvailability(parts); err != nil {
+		return err
+	}
+
+	byKit := make(map[uint][]int)
+	for i, component := range components {
+		byKit[component.KitID] = append(byKit[component.KitID], i)
+	}
+
+	for i := range items {
+		if !items[i].IsKit {
+			continue
+		}
+
+		stock, available := 0, 0
+		for n, idx := range byKit[items[i].ID] {
+			quantity := components[idx].Quantity
+			kitStock := parts[idx].Stock / quantity
+			kitAvailable := parts[idx].Available / quantity
+			if n == 0 || kitStock < stock {
+				stock = kitStock
+			}
+			if n == 0 || kitAvailable < available {
+				available = kitAvailable
+			}
+		}
+		if stock < 0 {
+			stock = 0
+		}
+
+		items[i].Stock = stock
+		items[i].Available = available
+		items[i].Reserved = stock - available
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type LocationService interface {
+	GetLocations() ([]models.Location, error)
+	CreateLocation(input dtos.CreateLocationInput, actor common.Actor) (*models.Location, error)
+	UpdateLocation(id string, input dtos.CreateLocationInput, actor common.Actor) (*models.Location, error)
+}
+
+type locationService struct{}
+
+func NewLocationService() LocationService {
+	return &locationService{}
+}
+
+func (s *locationService) GetLocations() ([]models.Location, error) {
+	var locations []models.Location
+	if err := config.DB.Order("is_default DESC, name").Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+func (s *locationService) CreateLocation(input dtos.CreateLocationInput, actor common.Actor) (*models.Location, error) {
+	var existing models.Location
+	if err := config.DB.Where("name = ? OR code = ?", input.Name, strings.ToUpper(input.Code)).
+		First(&existing).Error; err == nil {
+		return nil, errors.New("location already exists")
+	}
+
+	location := models.Location{
+		Name:      input.Name,
+		Code:      strings.ToUpper(input.Code),
+		IsDefault: input.IsDefault,
+	}
+
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if location.IsDefault {
+			if err := clearDefaultLocation(tx); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(&location).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Location '%s' created", location.Name)
+		return log.CreateAuditLog(tx, "location", "create", location.ID, nil, location, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &location, nil
+}
+
+func (s *locationService) UpdateLocation(id string, input dtos.CreateLocationInput, actor common.Actor) (*models.Location, error) {
+	var location models.Location
+	if err := config.DB.First(&location, id).Error; err != nil {
+		return nil, errors.New("location not found")
+	}
+
+	var existing models.Location
+	if err := config.DB.Where("(name = ? OR code = ?) AND id != ?", input.Name, strings.ToUpper(input.Code), location.ID).
+		First(&existing).Error; err == nil {
+		return nil, errors.New("location already exists")
+	}
+
+	if location.IsDefault && !input.IsDefault {
+		return nil, errors.New("set another location as default instead")
+	}
+
+	oldLocation := location
+	location.Name = input.Name
+	location.Code = strings.ToUpper(input.Code)
+
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if input.IsDefault && !location.IsDefault {
+			if err := clearDefaultLocation(tx); err != nil {
+				return err
+			}
+			location.IsDefault = true
+		}
+		if err := tx.Save(&location).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Location '%s' updated", location.Name)
+		return log.CreateAuditLog(tx, "location", "update", location.ID, oldLocation, location, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &location, nil
+}
+
+func clearDefaultLocation(tx *gorm.DB) error {
+	return tx.Model(&models.Location{}).Where("is_default = ?", true).
+		Update("is_default", false).Error
+}
+
+// resolveLocationID picks the location stock moves at: the explicit one if given,
+// otherwise the user's open cash session location, otherwise the default location.
+func resolveLocationID(tx *gorm.DB, locationID *uint, userID *uint) (uint, error) {
+	if locationID != nil && *locationID != 0 {
+		var location models.Location
+		if err := tx.First(&location, *locationID).Error; err != nil {
+			return 0, errors.New("location not found")
+		}
+		return location.ID, nil
+	}
+
+	if userID != nil {
+		var session models.CashSession
+		err := tx.Where("user_id = ? AND status = 'open' AND location_id IS NOT NULL", *userID).
+			First(&session).Error
+		if err == nil {
+			return *session.LocationID, nil
+		}
+	}
+
+	var location models.Location
+	if err := tx.Where("is_default = ?", true).First(&location).Error; err != nil {
+		return 0, errors.New("no default location configured")
+	}
+	return location.ID, nil
+}
+
+// withDeleted lets a preload include soft-deleted rows, so documents and history
+// keep showing items that were deleted later.
+func withDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
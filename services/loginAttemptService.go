@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils"
+	"kd-api/utils/common"
+	logutil "kd-api/utils/log"
+	"kd-api/utils/pagination"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type LoginAttemptService interface {
+	GetLoginAttempts(filter dtos.LoginAttemptFilter) (*dtos.LoginAttemptListResponse, error)
+}
+
+type loginAttemptService struct{}
+
+func NewLoginAttemptService() LoginAttemptService {
+	return &loginAttemptService{}
+}
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// compareDummyPassword spends the time of a real password check, so unknown
+// usernames cannot be told apart by response time.
+func compareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("kd-api-dummy-password"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+func normalizeLoginUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+func recordLoginAttempt(username string, userID *uint, terminalID *uint, clientIP string, userAgent string, reason string) {
+	if len(username) > 100 {
+		username = username[:100]
+	}
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.LoginAttempt{
+			Username:   username,
+			UserID:     userID,
+			TerminalID: terminalID,
+			IPAddress:  clientIP,
+			UserAgent:  userAgent,
+			Success:    reason == "ok",
+			Reason:     reason,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Successful logins also go to the audit log, next to what the user then did
+		if reason != "ok" || userID == nil {
+			return nil
+		}
+		description := fmt.Sprintf("User '%s' logged in", username)
+		actor := common.Actor{UserID: userID, IPAddress: clientIP, TerminalID: terminalID}
+		return logutil.CreateAuditLog(tx, "user", "login", *userID, nil, nil, nil, actor, description)
+	})
+	if err != nil {
+		log.Println("Failed to record login attempt:", err)
+	}
+}
+
+// lockedUntil works out when logins for a username or an address unlock again. The
+// failures counted are those within the policy window before the latest one, so a
+// lockout keeps its length while nobody tries. For usernames a successful login
+// starts the count again.
+func lockedUntil(column string, value string, limit int, resetOnSuccess bool, policy utils.LoginPolicy) (time.Time, error) {
+	var last models.LoginAttempt
+	err := config.DB.Where(column+" = ? AND success = ? AND reason != ?", value, false, "locked").
+		Order("created_at DESC, id DESC").
+		Limit(1).
+		Find(&last).Error
+	if err != nil || last.ID == 0 {
+		return time.Time{}, err
+	}
+
+	since := last.CreatedAt.Add(-policy.Window)
+	if resetOnSuccess {
+		var success models.LoginAttempt
+		if err := config.DB.Where(column+" = ? AND success = ?", value, true).
+			Order("created_at DESC, id DESC").
+			Limit(1).
+			Find(&success).Error; err != nil {
+			return time.Time{}, err
+		}
+		if success.ID != 0 && success.CreatedAt.After(since) {
+			since = success.CreatedAt
+		}
+	}
+
+	var failures int64
+	if err := config.DB.Model(&models.LoginAttempt{}).
+		Where(column+" = ? AND success = ? AND reason != ? AND created_at > ? AND id <= ?", value, false, "locked", since, last.ID).
+		Count(&failures).Error; err != nil {
+		return time.Time{}, err
+	}
+
+	return last.CreatedAt.Add(policy.LockoutFor(int(failures), limit)), nil
+}
+
+// loginLockout returns how long the username or address stays locked, zero when
+// a login may be tried.
+func loginLockout(username string, clientIP string) (time.Duration, error) {
+	policy := utils.GetLoginPolicy()
+
+	userUntil, err := lockedUntil("username", username, policy.MaxFailures, true, policy)
+	if err != nil {
+		return 0, err
+	}
+	ipUntil, err := lockedUntil("ip_address", clientIP, policy.MaxFailuresPerIP, false, policy)
+	if err != nil {
+		return 0, err
+	}
+
+	until := userUntil
+	if ipUntil.After(until) {
+		until = ipUntil
+	}
+	if wait := time.Until(until); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+func (s *loginAttemptService) GetLoginAttempts(filter dtos.LoginAttemptFilter) (*dtos.LoginAttemptListResponse, error) {
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PageSize < 1 || filter.PageSize > 100 {
+		filter.PageSize = 20
+	}
+
+	p := pagination.New(filter.Page, filter.PageSize)
+
+	query := config.DB.Model(&models.LoginAttempt{})
+	if filter.Username != "" {
+		query = query.Where("username = ?", normalizeLoginUsername(filter.Username))
+	}
+	if filter.IPAddress != "" {
+		query = query.Where("ip_address = ?", filter.IPAddress)
+	}
+	if filter.Success != nil {
+		query = query.Where("success = ?", *filter.Success)
+	}
+	if filter.StartDate != "" {
+		if _, err := time.Parse("2006-01-02", filter.StartDate); err != nil {
+			return nil, errors.New("Invalid date format, use YYYY-MM-DD")
+		}
+		query = query.Where("created_at >= ?", filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		if _, err := time.Parse("2006-01-02", filter.EndDate); err != nil {
+			return nil, errors.New("Invalid date format, use YYYY-MM-DD")
+		}
+		query = query.Where("created_at <= ?", filter.EndDate+" 23:59:59")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var attempts []models.LoginAttempt
+	if err := query.Order("created_at DESC, id DESC").
+		Offset(p.Offset).
+		Limit(p.PageSize).
+		Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	return &dtos.LoginAttemptListResponse{
+		Data: attempts,
+		Meta: dtos.PaginationMeta{
+			Page:       p.Page,
+			Limit:      p.PageSize,
+			Total:      total,
+			TotalPages: int((total + int64(p.PageSize) - 1) / int64(p.PageSize)),
+		},
+	}, nil
+}
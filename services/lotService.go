@@ -0,0 +1,302 @@
+package services
+
+import (
+	"errors"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/permission"
+	"kd-api/utils/response"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LotService interface {
+	CreateLot(tx *gorm.DB, itemID uint, locationID uint, batchNumber string, expiryDate *time.Time, quantity int, receiptID *uint) (*models.Lot, error)
+	ConsumeLots(tx *gorm.DB, itemID uint, locationID uint, quantity int, transactionItemID *uint) ([]models.TransactionItemLot, error)
+	ReturnLots(tx *gorm.DB, transactionItemID uint, locationID uint) ([]models.TransactionItemLot, error)
+	ReceiveLot(tx *gorm.DB, lotID uint, locationID uint, quantity int) (*models.Lot, error)
+	GetLots(filter dtos.LotFilter, role string) (*dtos.LotListResponse, error)
+	GetExpiringLots(days int, role string) (interface{}, error)
+	GetLotTransactions(lotID string) ([]dtos.LotTransaction, error)
+}
+
+type lotService struct{}
+
+func NewLotService() LotService {
+	return &lotService{}
+}
+
+func (s *lotService) CreateLot(tx *gorm.DB, itemID uint, locationID uint, batchNumber string, expiryDate *time.Time, quantity int, receiptID *uint) (*models.Lot, error) {
+	lot := models.Lot{
+		ItemID:         itemID,
+		LocationID:     locationID,
+		BatchNumber:    batchNumber,
+		ExpiryDate:     expiryDate,
+		Quantity:       quantity,
+		Remaining:      quantity,
+		GoodsReceiptID: receiptID,
+	}
+	if err := tx.Create(&lot).Error; err != nil {
+		return nil, err
+	}
+	return &lot, nil
+}
+
+// ConsumeLots takes quantity out of the item's lots at the location
+// first-expiry-first-out. Lots without an expiry date go last. When transactionItemID is set the allocation is
+// stored so refunds and recalls can find it. Quantity not covered by any lot is
+// simply not allocated.
+func (s *lotService) ConsumeLots(tx *gorm.DB, itemID uint, locationID uint, quantity int, transactionItemID *uint) ([]models.TransactionItemLot, error) {
+	if quantity <= 0 {
+		return nil, nil
+	}
+
+	var lots []models.Lot
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("item_id = ? AND location_id = ? AND remaining > 0", itemID, locationID).
+		Order("expiry_date IS NULL, expiry_date, id").
+		Find(&lots).Error; err != nil {
+		return nil, err
+	}
+
+	var allocations []models.TransactionItemLot
+	remaining := quantity
+	for _, lot := range lots {
+		if remaining == 0 {
+			break
+		}
+
+		take := lot.Remaining
+		if take > remaining {
+			take = remaining
+		}
+
+		if err := tx.Model(&models.Lot{}).Where("id = ?", lot.ID).
+			Update("remaining", lot.Remaining-take).Error; err != nil {
+			return nil, err
+		}
+
+		allocation := models.TransactionItemLot{LotID: lot.ID, Quantity: take}
+		if transactionItemID != nil {
+			allocation.TransactionItemID = *transactionItemID
+			if err := tx.Create(&allocation).Error; err != nil {
+				return nil, err
+			}
+		}
+		allocations = append(allocations, allocation)
+		remaining -= take
+	}
+
+	return allocations, nil
+}
+
+// ReturnLots puts a refunded transaction item back into the lots it was sold from,
+// those at the location the stock returns to.
+func (s *lotService) ReturnLots(tx *gorm.DB, transactionItemID uint, locationID uint) ([]models.TransactionItemLot, error) {
+	var allocations []models.TransactionItemLot
+	if err := tx.Joins("JOIN lots ON lots.id = transaction_item_lots.lot_id").
+		Where("transaction_item_lots.transaction_item_id = ? AND lots.location_id = ?", transactionItemID, locationID).
+		Find(&allocations).Error; err != nil {
+		return nil, err
+	}
+
+	for _, allocation := range allocations {
+		if err := tx.Model(&models.Lot{}).Where("id = ?", allocation.LotID).
+			Update("remaining", gorm.Expr("remaining + ?", allocation.Quantity)).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return allocations, nil
+}
+
+// ReceiveLot adds quantity taken from a lot elsewhere to the lot of the same batch
+// at the location, starting that lot when the batch has none there yet.
+func (s *lotService) ReceiveLot(tx *gorm.DB, lotID uint, locationID uint, quantity int) (*models.Lot, error) {
+	var source models.Lot
+	if err := tx.First(&source, lotID).Error; err != nil {
+		return nil, err
+	}
+	if source.LocationID == locationID {
+		return &source, tx.Model(&models.Lot{}).Where("id = ?", source.ID).
+			Update("remaining", gorm.Expr("remaining + ?", quantity)).Error
+	}
+
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("item_id = ? AND location_id = ? AND batch_number = ?", source.ItemID, locationID, source.BatchNumber)
+	if source.GoodsReceiptID != nil {
+		query = query.Where("goods_receipt_id = ?", *source.GoodsReceiptID)
+	} else {
+		query = query.Where("goods_receipt_id IS NULL")
+	}
+	var lot models.Lot
+	if err := query.Limit(1).Find(&lot).Error; err != nil {
+		return nil, err
+	}
+	if lot.ID == 0 {
+		return s.CreateLot(tx, source.ItemID, locationID, source.BatchNumber, source.ExpiryDate, quantity, source.GoodsReceiptID)
+	}
+
+	if err := tx.Model(&lot).Updates(map[string]any{
+		"quantity":  gorm.Expr("quantity + ?", quantity),
+		"remaining": gorm.Expr("remaining + ?", quantity),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &lot, nil
+}
+
+func (s *lotService) GetLots(filter dtos.LotFilter, role string) (*dtos.LotListResponse, error) {
+	var lots []models.Lot
+	var total int64
+
+	db := config.DB.Model(&models.Lot{})
+
+	if filter.ItemID != 0 {
+		db = db.Where("item_id = ?", filter.ItemID)
+	}
+	if filter.LocationID != 0 {
+		db = db.Where("location_id = ?", filter.LocationID)
+	}
+	if filter.BatchNumber != "" {
+		db = db.Where("batch_number = ?", filter.BatchNumber)
+	}
+	if filter.InStockOnly {
+		db = db.Where("remaining > 0")
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 10
+	}
+	offset := (filter.Page - 1) * filter.Limit
+
+	if err := db.Preload("Item", withDeleted).
+		Order("expiry_date IS NULL, expiry_date, id").
+		Limit(filter.Limit).
+		Offset(offset).
+		Find(&lots).Error; err != nil {
+		return nil, err
+	}
+
+	return &dtos.LotListResponse{
+		Data:       response.FilterLots(lots, roleCan(role, permission.ItemsViewCost)),
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: int((total + int64(filter.Limit) - 1) / int64(filter.Limit)),
+	}, nil
+}
+
+// GetExpiringLots lists lots still in stock that expire within the given days,
+// including the ones that already expired.
+func (s *lotService) GetExpiringLots(days int, role string) (interface{}, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	until := today.AddDate(0, 0, days)
+
+	var lots []models.Lot
+	if err := config.DB.Preload("Item", withDeleted).
+		Where("remaining > 0 AND expiry_date IS NOT NULL AND expiry_date <= ?", until).
+		Order("expiry_date, id").
+		Find(&lots).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]dtos.ExpiringLot, len(lots))
+	for i, lot := range lots {
+		daysLeft := int(lot.ExpiryDate.Sub(today).Hours() / 24)
+		result[i] = dtos.ExpiringLot{
+			Lot:      lot,
+			DaysLeft: daysLeft,
+			Expired:  daysLeft < 0,
+		}
+	}
+	return response.FilterExpiringLots(result, roleCan(role, permission.ItemsViewCost)), nil
+}
+
+// GetLotTransactions lists the sales that took stock from a lot, for recalls.
+func (s *lotService) GetLotTransactions(lotID string) ([]dtos.LotTransaction, error) {
+	var lot models.Lot
+	if err := config.DB.First(&lot, lotID).Error; err != nil {
+		return nil, errors.New("lot not found")
+	}
+
+	result := []dtos.LotTransaction{}
+	if err := config.DB.Table("transaction_item_lots").
+		Select("transactions.id AS transaction_id, transactions.status, "+
+			"SUM(transaction_item_lots.quantity) AS quantity, transactions.created_at").
+		Joins("JOIN transaction_items ON transaction_items.id = transaction_item_lots.transaction_item_id").
+		Joins("JOIN transactions ON transactions.id = transaction_items.transaction_id").
+		Where("transaction_item_lots.lot_id = ?", lot.ID).
+		Group("transactions.id, transactions.status, transactions.created_at").
+		Order("transactions.created_at DESC").
+		Scan(&result).Error; err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// allocationsForItem keeps the allocations taken from lots of the given item. A kit
+// line holds allocations for each of its components.
+func allocationsForItem(tx *gorm.DB, allocations []models.TransactionItemLot, itemID uint) []models.TransactionItemLot {
+	if len(allocations) == 0 {
+		return nil
+	}
+
+	lotIDs := make([]uint, len(allocations))
+	for i, allocation := range allocations {
+		lotIDs[i] = allocation.LotID
+	}
+
+	var ids []uint
+	tx.Model(&models.Lot{}).Where("id IN ? AND item_id = ?", lotIDs, itemID).Pluck("id", &ids)
+
+	own := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		own[id] = true
+	}
+
+	var result []models.TransactionItemLot
+	for _, allocation := range allocations {
+		if own[allocation.LotID] {
+			result = append(result, allocation)
+		}
+	}
+	return result
+}
+
+// logStockChangeByLot writes one ledger entry per lot the change touched and one
+// untagged entry for whatever was not covered by a lot.
+func logStockChangeByLot(tx *gorm.DB, itemID uint, change int, allocations []models.TransactionItemLot, logType string, refID string, userID *uint, note string, locationID *uint) error {
+	invService := NewInventoryService()
+
+	sign := 1
+	if change < 0 {
+		sign = -1
+	}
+
+	rest := change
+	for _, allocation := range allocations {
+		lotID := allocation.LotID
+		if err := invService.LogStockChange(tx, itemID, sign*allocation.Quantity, logType, refID, userID, note, locationID, &lotID); err != nil {
+			return err
+		}
+		rest -= sign * allocation.Quantity
+	}
+
+	if rest != 0 || len(allocations) == 0 {
+		return invService.LogStockChange(tx, itemID, rest, logType, refID, userID, note, locationID, nil)
+	}
+	return nil
+}
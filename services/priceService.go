@@ -0,0 +1,257 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	logutil "kd-api/utils/log"
+	"kd-api/utils/permission"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PriceService interface {
+	GetPriceHistory(itemID string, filter dtos.PriceHistoryFilter, role string) (*dtos.PriceHistoryResponse, error)
+	SchedulePriceChange(itemID string, input dtos.SchedulePriceChangeInput, actor common.Actor) (*models.ScheduledPriceChange, error)
+	CancelScheduledChange(itemID string, scheduleID string, actor common.Actor) error
+	ApplyDuePriceChanges() (int, error)
+}
+
+type priceService struct{}
+
+func NewPriceService() PriceService {
+	return &priceService{}
+}
+
+// recordPriceChange writes a history entry when the selling or buy price differs
+// between the two versions of the item.
+func recordPriceChange(tx *gorm.DB, oldItem, newItem models.Item, source string, scheduledChangeID *uint, userID *uint) error {
+	if oldItem.Price == newItem.Price && oldItem.BuyPrice == newItem.BuyPrice && source != "create" {
+		return nil
+	}
+
+	entry := models.PriceHistory{
+		ItemID:            newItem.ID,
+		OldPrice:          oldItem.Price,
+		NewPrice:          newItem.Price,
+		OldBuyPrice:       oldItem.BuyPrice,
+		NewBuyPrice:       newItem.BuyPrice,
+		Source:            source,
+		ScheduledChangeID: scheduledChangeID,
+		ChangedBy:         userID,
+	}
+	return tx.Create(&entry).Error
+}
+
+func (s *priceService) GetPriceHistory(itemID string, filter dtos.PriceHistoryFilter, role string) (*dtos.PriceHistoryResponse, error) {
+	var item models.Item
+	if err := config.DB.Unscoped().First(&item, itemID).Error; err != nil {
+		return nil, errors.New("Item not found")
+	}
+
+	db := config.DB.Where("item_id = ?", item.ID)
+	if filter.StartDate != "" {
+		if _, err := time.Parse("2006-01-02", filter.StartDate); err != nil {
+			return nil, errors.New("Invalid date format, use YYYY-MM-DD")
+		}
+		db = db.Where("created_at >= ?", filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		if _, err := time.Parse("2006-01-02", filter.EndDate); err != nil {
+			return nil, errors.New("Invalid date format, use YYYY-MM-DD")
+		}
+		db = db.Where("created_at <= ?", filter.EndDate+" 23:59:59")
+	}
+
+	var entries []models.PriceHistory
+	if err := db.Order("created_at, id").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	response := dtos.PriceHistoryResponse{
+		ItemID:  item.ID,
+		Name:    item.Name,
+		History: make([]dtos.PriceHistoryEntry, len(entries)),
+	}
+	showCost := roleCan(role, permission.ItemsViewCost)
+	for i, entry := range entries {
+		response.History[i] = dtos.PriceHistoryEntry{
+			Date:     entry.CreatedAt,
+			OldPrice: entry.OldPrice,
+			Price:    entry.NewPrice,
+			Source:   entry.Source,
+		}
+		if showCost {
+			oldBuyPrice, buyPrice := entry.OldBuyPrice, entry.NewBuyPrice
+			response.History[i].OldBuyPrice = &oldBuyPrice
+			response.History[i].BuyPrice = &buyPrice
+		}
+	}
+
+	if showCost {
+		if err := config.DB.Where("item_id = ? AND status = ?", item.ID, "pending").
+			Order("effective_at").
+			Find(&response.Upcoming).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &response, nil
+}
+
+func (s *priceService) SchedulePriceChange(itemID string, input dtos.SchedulePriceChangeInput, actor common.Actor) (*models.ScheduledPriceChange, error) {
+	var item models.Item
+	if err := config.DB.First(&item, itemID).Error; err != nil {
+		return nil, errors.New("Item not found")
+	}
+
+	if input.Price == nil && input.BuyPrice == nil {
+		return nil, errors.New("price or buy_price is required")
+	}
+
+	effectiveAt, err := time.Parse(time.RFC3339, input.EffectiveAt)
+	if err != nil {
+		effectiveAt, err = time.ParseInLocation("2006-01-02", input.EffectiveAt, time.Local)
+		if err != nil {
+			return nil, errors.New("Invalid date format, use YYYY-MM-DD or RFC3339")
+		}
+	}
+	if !effectiveAt.After(time.Now()) {
+		return nil, errors.New("effective_at must be in the future")
+	}
+
+	change := models.ScheduledPriceChange{
+		ItemID:      item.ID,
+		Price:       input.Price,
+		BuyPrice:    input.BuyPrice,
+		EffectiveAt: effectiveAt,
+		Status:      "pending",
+		Note:        input.Note,
+		CreatedBy:   actor.UserID,
+	}
+	err = logutil.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&change).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Price change for item '%s' scheduled at %s", item.Name, effectiveAt.Format(time.RFC3339))
+		return logutil.CreateAuditLog(tx, "scheduled_price_change", "create", change.ID, nil, change, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+func (s *priceService) CancelScheduledChange(itemID string, scheduleID string, actor common.Actor) error {
+	return logutil.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var change models.ScheduledPriceChange
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND item_id = ? AND status = ?", scheduleID, itemID, "pending").
+			First(&change).Error; err != nil {
+			return errors.New("scheduled change not found")
+		}
+		oldChange := change
+
+		change.Status = "cancelled"
+		if err := tx.Model(&change).Update("status", "cancelled").Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Scheduled price change #%d cancelled", change.ID)
+		return logutil.CreateAuditLog(tx, "scheduled_price_change", "status_change", change.ID, oldChange, change, nil, actor, description)
+	})
+}
+
+// ApplyDuePriceChanges applies pending changes whose time has come, oldest first,
+// and returns how many were applied. Changes for deleted items are cancelled.
+func (s *priceService) ApplyDuePriceChanges() (int, error) {
+	var due []models.ScheduledPriceChange
+	if err := config.DB.Where("status = ? AND effective_at <= ?", "pending", time.Now()).
+		Order("effective_at, id").
+		Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, change := range due {
+		done := false
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			var item models.Item
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, change.ItemID).Error; err != nil {
+				return tx.Model(&models.ScheduledPriceChange{}).
+					Where("id = ? AND status = ?", change.ID, "pending").
+					Update("status", "cancelled").Error
+			}
+
+			now := time.Now()
+			res := tx.Model(&models.ScheduledPriceChange{}).
+				Where("id = ? AND status = ?", change.ID, "pending").
+				Updates(map[string]any{"status": "applied", "applied_at": now})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				// Cancelled or applied in the meantime
+				return nil
+			}
+
+			oldItem := item
+			if change.Price != nil {
+				item.Price = *change.Price
+			}
+			if change.BuyPrice != nil {
+				item.BuyPrice = *change.BuyPrice
+			}
+
+			if err := tx.Model(&models.Item{}).Where("id = ?", item.ID).
+				Updates(map[string]any{"price": item.Price, "buy_price": item.BuyPrice}).Error; err != nil {
+				return err
+			}
+
+			changeID := change.ID
+			if err := recordPriceChange(tx, oldItem, item, "scheduled", &changeID, change.CreatedBy); err != nil {
+				return err
+			}
+
+			done = true
+			description := fmt.Sprintf("Scheduled price change #%d applied to item '%s'", change.ID, item.Name)
+			return logutil.CreateItemAuditLog(
+				tx,
+				"update",
+				item.ID,
+				&oldItem,
+				&item,
+				common.Actor{UserID: change.CreatedBy},
+				description,
+			)
+		})
+		if err != nil {
+			return applied, err
+		}
+		if done {
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// StartPriceScheduler runs ApplyDuePriceChanges periodically in the background.
+func StartPriceScheduler(interval time.Duration) {
+	go func() {
+		service := NewPriceService()
+		for range time.Tick(interval) {
+			if _, err := service.ApplyDuePriceChanges(); err != nil {
+				log.Println("Failed to apply scheduled price changes:", err)
+			}
+		}
+	}()
+}
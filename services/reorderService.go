@@ -0,0 +1,309 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReorderService interface {
+	GetSuggestions(filter dtos.ReorderFilter) ([]dtos.ReorderSuggestion, error)
+	GroupBySupplier(suggestions []dtos.ReorderSuggestion) ([]dtos.SupplierReorderGroup, error)
+	CreatePurchaseOrders(input dtos.CreatePurchaseOrdersInput, actor common.Actor) ([]models.PurchaseOrder, error)
+	GetPurchaseOrders(status string) ([]models.PurchaseOrder, error)
+	GetPurchaseOrderByID(id string) (*models.PurchaseOrder, error)
+}
+
+type reorderService struct{}
+
+func NewReorderService() ReorderService {
+	return &reorderService{}
+}
+
+// lowStockCondition is the SQL condition for an item that needs attention: at or
+// below its reorder point, under its minimum stock, or under LOW_STOCK_THRESHOLD
+// (default 5) when neither is configured. Kits hold no stock and never match.
+func lowStockCondition() (string, []interface{}) {
+	threshold, err := strconv.Atoi(os.Getenv("LOW_STOCK_THRESHOLD"))
+	if err != nil || threshold < 0 {
+		threshold = 5
+	}
+
+	return "items.is_kit = false AND CASE WHEN items.reorder_point > 0 THEN items.stock <= items.reorder_point " +
+		"WHEN items.min_stock > 0 THEN items.stock < items.min_stock " +
+		"ELSE items.stock < ? END", []interface{}{threshold}
+}
+
+func defaultLeadTimeDays() int {
+	days, err := strconv.Atoi(os.Getenv("DEFAULT_LEAD_TIME_DAYS"))
+	if err != nil || days < 0 {
+		return 7
+	}
+	return days
+}
+
+// GetSuggestions works out, per item, the stock needed to cover sales during the
+// supplier's lead time. Items at or below that reorder point get a suggested quantity:
+// the item's reorder quantity (in multiples), or enough for another lookback period.
+func (s *reorderService) GetSuggestions(filter dtos.ReorderFilter) ([]dtos.ReorderSuggestion, error) {
+	if filter.Days < 1 {
+		filter.Days = 30
+	}
+	since := time.Now().AddDate(0, 0, -filter.Days)
+
+	var items []models.Item
+	if err := config.DB.Preload("Supplier").Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	var sales []struct {
+		ItemID   uint
+		Quantity int
+	}
+	if err := config.DB.Model(&models.TransactionItem{}).
+		Select("transaction_items.item_id, SUM(transaction_items.quantity) AS quantity").
+		Joins("JOIN transactions ON transactions.id = transaction_items.transaction_id").
+		Where("transactions.status = ? AND transactions.created_at >= ?", "completed", since).
+		Group("transaction_items.item_id").
+		Scan(&sales).Error; err != nil {
+		return nil, err
+	}
+	sold := make(map[uint]int, len(sales))
+	for _, row := range sales {
+		sold[row.ItemID] = row.Quantity
+	}
+
+	// Kits sold count as demand for their components
+	var kitSales []struct {
+		ItemID   uint
+		Quantity int
+	}
+	if err := config.DB.Model(&models.TransactionItem{}).
+		Select("kit_components.component_id AS item_id, SUM(transaction_items.quantity * kit_components.quantity) AS quantity").
+		Joins("JOIN kit_components ON kit_components.kit_id = transaction_items.item_id").
+		Joins("JOIN transactions ON transactions.id = transaction_items.transaction_id").
+		Where("transactions.status = ? AND transactions.created_at >= ?", "completed", since).
+		Group("kit_components.component_id").
+		Scan(&kitSales).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range kitSales {
+		sold[row.ItemID] += row.Quantity
+	}
+
+	var orders []struct {
+		ItemID   uint
+		Quantity int
+	}
+	if err := config.DB.Model(&models.PurchaseOrderItem{}).
+		Select("purchase_order_items.item_id, SUM(purchase_order_items.quantity) AS quantity").
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id").
+		Where("purchase_orders.status IN ?", []string{"draft", "ordered"}).
+		Group("purchase_order_items.item_id").
+		Scan(&orders).Error; err != nil {
+		return nil, err
+	}
+	onOrder := make(map[uint]int, len(orders))
+	for _, row := range orders {
+		onOrder[row.ItemID] = row.Quantity
+	}
+
+	suggestions := []dtos.ReorderSuggestion{}
+	for _, item := range items {
+		// Kits are replenished through their components
+		if item.IsKit {
+			continue
+		}
+
+		leadTime := defaultLeadTimeDays()
+		if item.Supplier != nil {
+			leadTime = item.Supplier.LeadTimeDays
+		}
+
+		velocity := float64(sold[item.ID]) / float64(filter.Days)
+
+		reorderPoint := item.ReorderPoint
+		if reorderPoint == 0 {
+			reorderPoint = int(math.Ceil(velocity*float64(leadTime))) + item.MinStock
+		}
+
+		position := item.Stock + onOrder[item.ID]
+		if position > reorderPoint {
+			continue
+		}
+
+		quantity := 0
+		if item.ReorderQty > 0 {
+			for position+quantity <= reorderPoint {
+				quantity += item.ReorderQty
+			}
+		} else {
+			quantity = int(math.Ceil(float64(reorderPoint)+velocity*float64(filter.Days))) - position
+		}
+		if quantity <= 0 {
+			continue
+		}
+
+		suggestions = append(suggestions, dtos.ReorderSuggestion{
+			ItemID:        item.ID,
+			Name:          item.Name,
+			SupplierID:    item.SupplierID,
+			Stock:         item.Stock,
+			OnOrder:       onOrder[item.ID],
+			SoldInPeriod:  sold[item.ID],
+			DailyVelocity: math.Round(velocity*100) / 100,
+			LeadTimeDays:  leadTime,
+			ReorderPoint:  reorderPoint,
+			SuggestedQty:  quantity,
+			UnitCost:      item.AverageCost,
+		})
+	}
+
+	return suggestions, nil
+}
+
+func (s *reorderService) GroupBySupplier(suggestions []dtos.ReorderSuggestion) ([]dtos.SupplierReorderGroup, error) {
+	var suppliers []models.Supplier
+	if err := config.DB.Find(&suppliers).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Supplier, len(suppliers))
+	for _, supplier := range suppliers {
+		byID[supplier.ID] = supplier
+	}
+
+	groups := map[uint]*dtos.SupplierReorderGroup{}
+	for _, suggestion := range suggestions {
+		key := uint(0)
+		if suggestion.SupplierID != nil {
+			key = *suggestion.SupplierID
+		}
+
+		group, ok := groups[key]
+		if !ok {
+			group = &dtos.SupplierReorderGroup{
+				SupplierName: "Tanpa supplier",
+				LeadTimeDays: defaultLeadTimeDays(),
+			}
+			if supplier, found := byID[key]; found {
+				id := supplier.ID
+				group.SupplierID = &id
+				group.SupplierName = supplier.Name
+				group.LeadTimeDays = supplier.LeadTimeDays
+			}
+			groups[key] = group
+		}
+
+		group.Items = append(group.Items, suggestion)
+		group.EstimatedTotal += float64(suggestion.SuggestedQty) * suggestion.UnitCost
+	}
+
+	result := make([]dtos.SupplierReorderGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].SupplierName < result[j].SupplierName
+	})
+	return result, nil
+}
+
+// CreatePurchaseOrders turns the current suggestions into one draft purchase order per supplier.
+func (s *reorderService) CreatePurchaseOrders(input dtos.CreatePurchaseOrdersInput, actor common.Actor) ([]models.PurchaseOrder, error) {
+	suggestions, err := s.GetSuggestions(dtos.ReorderFilter{Days: input.Days})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input.ItemIDs) > 0 {
+		wanted := make(map[uint]bool, len(input.ItemIDs))
+		for _, id := range input.ItemIDs {
+			wanted[id] = true
+		}
+		filtered := suggestions[:0]
+		for _, suggestion := range suggestions {
+			if wanted[suggestion.ItemID] {
+				filtered = append(filtered, suggestion)
+			}
+		}
+		suggestions = filtered
+	}
+
+	if len(suggestions) == 0 {
+		return nil, errors.New("nothing to reorder")
+	}
+
+	groups, err := s.GroupBySupplier(suggestions)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]models.PurchaseOrder, 0, len(groups))
+	err = log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		for _, group := range groups {
+			note := fmt.Sprintf("Generated from reorder suggestions (%d days of sales)", input.Days)
+			order := models.PurchaseOrder{
+				SupplierID: group.SupplierID,
+				Status:     "draft",
+				Note:       &note,
+				CreatedBy:  actor.UserID,
+			}
+			for _, suggestion := range group.Items {
+				order.Items = append(order.Items, models.PurchaseOrderItem{
+					ItemID:   suggestion.ItemID,
+					Quantity: suggestion.SuggestedQty,
+					UnitCost: suggestion.UnitCost,
+				})
+			}
+
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+
+			description := fmt.Sprintf("Purchase order #%d drafted for %s", order.ID, group.SupplierName)
+			if err := log.CreateAuditLog(tx, "purchase_order", "create", order.ID, nil, order, nil, actor, description); err != nil {
+				return err
+			}
+			orders = append(orders, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (s *reorderService) GetPurchaseOrders(status string) ([]models.PurchaseOrder, error) {
+	var orders []models.PurchaseOrder
+
+	db := config.DB.Preload("Supplier").Preload("Items.Item", withDeleted)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	if err := db.Order("created_at DESC").Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+func (s *reorderService) GetPurchaseOrderByID(id string) (*models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	if err := config.DB.Preload("Supplier").Preload("Items.Item", withDeleted).First(&order, id).Error; err != nil {
+		return nil, errors.New("purchase order not found")
+	}
+	return &order, nil
+}
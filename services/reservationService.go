@@ -0,0 +1,211 @@
+package services
+
+import (
+	"fmt"
+	"kd-api/config"
+	"kd-api/models"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ReservationService interface {
+	ReserveForTransaction(tx *gorm.DB, transaction *models.Transaction) ([]string, error)
+	ReleaseForTransaction(tx *gorm.DB, transactionID uint, status string) error
+	ReservedQuantity(tx *gorm.DB, itemID uint, locationID *uint) (int, error)
+	ApplyAvailability(items []models.Item) error
+	ExpireReservations() (int64, error)
+}
+
+type reservationService struct {
+	ttl time.Duration
+}
+
+func NewReservationService() ReservationService {
+	return &reservationService{ttl: reservationTTL()}
+}
+
+// reservationTTL reads STOCK_RESERVATION_TTL, zero means reservations are disabled.
+func reservationTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("STOCK_RESERVATION_TTL"))
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// ReserveForTransaction holds the draft's quantities at its location. When less than
+// the requested quantity is available the rest is reserved and a warning is returned.
+func (s *reservationService) ReserveForTransaction(tx *gorm.DB, transaction *models.Transaction) ([]string, error) {
+	if s.ttl == 0 {
+		return nil, nil
+	}
+
+	locationID, err := resolveLocationID(tx, transaction.LocationID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []string
+	expiresAt := time.Now().Add(s.ttl)
+
+	var lines []stockLine
+	for _, tItem := range transaction.Items {
+		if tItem.Quantity <= 0 {
+			continue
+		}
+
+		var item models.Item
+		if err := tx.First(&item, tItem.ItemID).Error; err != nil {
+			return nil, err
+		}
+
+		// Kits reserve their components
+		itemLines, err := expandKit(tx, item, tItem.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, itemLines...)
+	}
+
+	// Stock rows are locked in item order so concurrent drafts cannot deadlock
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Item.ID < lines[j].Item.ID })
+
+	for _, line := range lines {
+		// Locking the stock level makes drafts for the same item and location wait
+		// for each other, so two of them cannot both reserve the last units
+		var level models.ItemStock
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("item_id = ? AND location_id = ?", line.Item.ID, locationID).
+			Find(&level).Error; err != nil {
+			return nil, err
+		}
+
+		reserved, err := s.ReservedQuantity(tx, line.Item.ID, &locationID)
+		if err != nil {
+			return nil, err
+		}
+
+		quantity := line.Quantity
+		if available := level.Quantity - reserved; available < quantity {
+			if available < 0 {
+				available = 0
+			}
+			warnings = append(warnings, fmt.Sprintf(
+				"Warning: Item #%d only %d of %d could be reserved",
+				line.Item.ID, available, line.Quantity,
+			))
+			quantity = available
+		}
+
+		if quantity == 0 {
+			continue
+		}
+
+		reservation := models.StockReservation{
+			TransactionID: transaction.ID,
+			ItemID:        line.Item.ID,
+			LocationID:    locationID,
+			Quantity:      quantity,
+			Status:        "active",
+			ExpiresAt:     expiresAt,
+		}
+		if err := tx.Create(&reservation).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return warnings, nil
+}
+
+// ReleaseForTransaction ends the transaction's active reservations with the given
+// status: "released" for a deleted draft, "consumed" when it became a sale.
+func (s *reservationService) ReleaseForTransaction(tx *gorm.DB, transactionID uint, status string) error {
+	return tx.Model(&models.StockReservation{}).
+		Where("transaction_id = ? AND status = ?", transactionID, "active").
+		Update("status", status).Error
+}
+
+// ReservedQuantity sums the active, unexpired reservations of an item, optionally
+// limited to one location.
+func (s *reservationService) ReservedQuantity(tx *gorm.DB, itemID uint, locationID *uint) (int, error) {
+	var reserved int64
+	query := tx.Model(&models.StockReservation{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("item_id = ? AND status = ? AND expires_at > ?", itemID, "active", time.Now())
+	if locationID != nil {
+		query = query.Where("location_id = ?", *locationID)
+	}
+	if err := query.Scan(&reserved).Error; err != nil {
+		return 0, err
+	}
+	return int(reserved), nil
+}
+
+// ApplyAvailability fills Item.Reserved and Item.Available (on hand minus reserved).
+// Kits get all three derived from their components.
+func (s *reservationService) ApplyAvailability(items []models.Item) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	var rows []struct {
+		ItemID   uint
+		Reserved int
+	}
+	if err := config.DB.Model(&models.StockReservation{}).
+		Select("item_id, SUM(quantity) AS reserved").
+		Where("item_id IN ? AND status = ? AND expires_at > ?", ids, "active", time.Now()).
+		Group("item_id").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	reserved := make(map[uint]int, len(rows))
+	for _, r := range rows {
+		reserved[r.ItemID] = r.Reserved
+	}
+
+	for i := range items {
+		items[i].Reserved = reserved[items[i].ID]
+		items[i].Available = items[i].Stock - items[i].Reserved
+		if items[i].Available < 0 {
+			items[i].Available = 0
+		}
+	}
+	return NewKitService().ApplyKitAvailability(config.DB, items)
+}
+
+// ExpireReservations marks reservations past their expiry as "expired".
+// Expired reservations are already ignored by reads, this only keeps the table tidy.
+func (s *reservationService) ExpireReservations() (int64, error) {
+	res := config.DB.Model(&models.StockReservation{}).
+		Where("status = ? AND expires_at <= ?", "active", time.Now()).
+		Update("status", "expired")
+	return res.RowsAffected, res.Error
+}
+
+// StartReservationExpiry runs ExpireReservations periodically in the background.
+func StartReservationExpiry(interval time.Duration) {
+	if reservationTTL() == 0 {
+		return
+	}
+
+	go func() {
+		service := NewReservationService()
+		for range time.Tick(interval) {
+			if _, err := service.ExpireReservations(); err != nil {
+				log.Println("Failed to expire stock reservations:", err)
+			}
+		}
+	}()
+}
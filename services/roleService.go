@@ -0,0 +1,334 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"kd-api/utils/permission"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How long a role's permissions and two-factor setting are kept in memory. Changes
+// made through this process take effect at once, those of another instance within
+// this time.
+const rolePermissionTTL = time.Minute
+
+type cachedRoleAccess struct {
+	permissions      map[string]bool
+	requireTwoFactor bool
+	loadedAt         time.Time
+}
+
+var (
+	rolePermissionCache   = make(map[string]cachedRoleAccess)
+	rolePermissionCacheMu sync.Mutex
+)
+
+type RoleService interface {
+	GetRoles() ([]models.Role, error)
+	GetRoleByID(id string) (*models.Role, error)
+	CreateRole(input dtos.CreateRoleInput, actor common.Actor) (*models.Role, error)
+	UpdateRole(id string, input dtos.UpdateRoleInput, actor common.Actor) (*models.Role, error)
+	DeleteRole(id string, actor common.Actor) error
+	Permissions(role string) (map[string]bool, error)
+	RequiresTwoFactor(role string) (bool, error)
+}
+
+type roleService struct{}
+
+func NewRoleService() RoleService {
+	return &roleService{}
+}
+
+func (s *roleService) GetRoles() ([]models.Role, error) {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (s *roleService) GetRoleByID(id string) (*models.Role, error) {
+	var role models.Role
+	if err := config.DB.Preload("Permissions").First(&role, id).Error; err != nil {
+		return nil, errors.New("Role not found")
+	}
+	return &role, nil
+}
+
+// validatePermissions drops duplicates and rejects names missing from the catalog.
+func validatePermissions(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !permission.Exists(name) {
+			return nil, fmt.Errorf("unknown permission '%s'", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func validateRoleName(name string) error {
+	if name == "" {
+		return errors.New("role name is required")
+	}
+	// Reserved for the roles API keys act under
+	if strings.HasPrefix(name, apiKeyRolePrefix) {
+		return fmt.Errorf("role names cannot start with '%s'", apiKeyRolePrefix)
+	}
+	return nil
+}
+
+func roleNameTaken(db *gorm.DB, name string, excludeID uint) bool {
+	var count int64
+	db.Model(&models.Role{}).Where("name = ? AND id != ?", name, excludeID).Count(&count)
+	return count > 0
+}
+
+// roleExists is used when assigning a role to a user.
+func roleExists(db *gorm.DB, name string) bool {
+	var count int64
+	db.Model(&models.Role{}).Where("name = ?", name).Count(&count)
+	return count > 0
+}
+
+func replaceRolePermissions(tx *gorm.DB, role *models.Role, names []string) error {
+	if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+	role.Permissions = make([]models.RolePermission, len(names))
+	for i, name := range names {
+		role.Permissions[i] = models.RolePermission{RoleID: role.ID, Permission: name}
+	}
+	if len(role.Permissions) == 0 {
+		return nil
+	}
+	return tx.Create(&role.Permissions).Error
+}
+
+func (s *roleService) CreateRole(input dtos.CreateRoleInput, actor common.Actor) (*models.Role, error) {
+	name := strings.ToLower(strings.TrimSpace(input.Name))
+	if err := validateRoleName(name); err != nil {
+		return nil, err
+	}
+	if roleNameTaken(config.DB, name, 0) {
+		return nil, errors.New("role already exists")
+	}
+	names, err := validatePermissions(input.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := models.Role{
+		Name:             name,
+		Description:      strings.TrimSpace(input.Description),
+		RequireTwoFactor: input.RequireTwoFactor,
+	}
+	err = log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(&role).Error; err != nil {
+			return err
+		}
+		if err := replaceRolePermissions(tx, &role, names); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Role '%s' created with %d permission(s)", role.Name, len(names))
+		return log.CreateAuditLog(tx, "role", "create", role.ID, nil, role, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateRolePermissions()
+	return &role, nil
+}
+
+// UpdateRole renames a role (its users follow), changes its description and
+// two-factor requirement or replaces its permissions. System roles keep their
+// name, and admin keeps every permission.
+func (s *roleService) UpdateRole(id string, input dtos.UpdateRoleInput, actor common.Actor) (*models.Role, error) {
+	var role models.Role
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Permissions").First(&role, id).Error; err != nil {
+			return errors.New("Role not found")
+		}
+		oldRole := role
+
+		if input.Name != nil {
+			name := strings.ToLower(strings.TrimSpace(*input.Name))
+			if name != role.Name {
+				if role.IsSystem {
+					return errors.New("system roles cannot be renamed")
+				}
+				if err := validateRoleName(name); err != nil {
+					return err
+				}
+				if roleNameTaken(tx, name, role.ID) {
+					return errors.New("role already exists")
+				}
+				if err := tx.Model(&models.User{}).Where("role = ?", role.Name).Update("role", name).Error; err != nil {
+					return err
+				}
+				role.Name = name
+			}
+		}
+		if input.Description != nil {
+			role.Description = strings.TrimSpace(*input.Description)
+		}
+		if input.RequireTwoFactor != nil {
+			role.RequireTwoFactor = *input.RequireTwoFactor
+		}
+		if err := tx.Model(&role).Omit(clause.Associations).Updates(map[string]any{
+			"name":               role.Name,
+			"description":        role.Description,
+			"require_two_factor": role.RequireTwoFactor,
+		}).Error; err != nil {
+			return err
+		}
+
+		if input.Permissions != nil {
+			if role.Name == "admin" {
+				return errors.New("the admin role always has every permission")
+			}
+			names, err := validatePermissions(input.Permissions)
+			if err != nil {
+				return err
+			}
+			if err := replaceRolePermissions(tx, &role, names); err != nil {
+				return err
+			}
+		}
+
+		description := fmt.Sprintf("Role '%s' updated", role.Name)
+		return log.CreateAuditLog(tx, "role", "update", role.ID, oldRole, role, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invalidateRolePermissions()
+	return &role, nil
+}
+
+// DeleteRole removes a role nobody has any more. System roles stay.
+func (s *roleService) DeleteRole(id string, actor common.Actor) error {
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var role models.Role
+		if err := tx.Preload("Permissions").First(&role, id).Error; err != nil {
+			return errors.New("Role not found")
+		}
+		if role.IsSystem {
+			return errors.New("system roles cannot be deleted")
+		}
+
+		var users int64
+		if err := tx.Model(&models.User{}).Where("role = ?", role.Name).Count(&users).Error; err != nil {
+			return err
+		}
+		if users > 0 {
+			return fmt.Errorf("role is assigned to %d user(s) and cannot be deleted", users)
+		}
+
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&role).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Role '%s' deleted", role.Name)
+		return log.CreateAuditLog(tx, "role", "delete", role.ID, role, nil, nil, actor, description)
+	})
+	if err != nil {
+		return err
+	}
+
+	invalidateRolePermissions()
+	return nil
+}
+
+// Permissions returns the set of permissions granted to the named role. Unknown
+// roles, including the empty role of anonymous requests, have none.
+func (s *roleService) Permissions(role string) (map[string]bool, error) {
+	access, err := roleAccess(role)
+	if err != nil {
+		return nil, err
+	}
+	return access.permissions, nil
+}
+
+// RequiresTwoFactor reports whether users of the role must use two-factor authentication.
+func (s *roleService) RequiresTwoFactor(role string) (bool, error) {
+	access, err := roleAccess(role)
+	if err != nil {
+		return false, err
+	}
+	return access.requireTwoFactor, nil
+}
+
+func roleAccess(role string) (cachedRoleAccess, error) {
+	rolePermissionCacheMu.Lock()
+	cached, ok := rolePermissionCache[role]
+	rolePermissionCacheMu.Unlock()
+	if ok && time.Since(cached.loadedAt) < rolePermissionTTL {
+		return cached, nil
+	}
+
+	access := cachedRoleAccess{permissions: make(map[string]bool), loadedAt: time.Now()}
+	if strings.HasPrefix(role, apiKeyRolePrefix) {
+		var granted []models.APIKeyPermission
+		if err := config.DB.Where("api_key_id = ?", strings.TrimPrefix(role, apiKeyRolePrefix)).Find(&granted).Error; err != nil {
+			return cachedRoleAccess{}, err
+		}
+		for _, grant := range granted {
+			access.permissions[grant.Permission] = true
+		}
+	} else if role != "" {
+		var stored models.Role
+		err := config.DB.Preload("Permissions").Where("name = ?", role).First(&stored).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return cachedRoleAccess{}, err
+		}
+		for _, granted := range stored.Permissions {
+			access.permissions[granted.Permission] = true
+		}
+		access.requireTwoFactor = stored.RequireTwoFactor
+	}
+
+	rolePermissionCacheMu.Lock()
+	rolePermissionCache[role] = access
+	rolePermissionCacheMu.Unlock()
+	return access, nil
+}
+
+func invalidateRolePermissions() {
+	rolePermissionCacheMu.Lock()
+	rolePermissionCache = make(map[string]cachedRoleAccess)
+	rolePermissionCacheMu.Unlock()
+}
+
+// roleCan reports whether the role has the permission, failing closed when the
+// permissions cannot be read.
+func roleCan(role string, name string) bool {
+	permissions, err := NewRoleService().Permissions(role)
+	if err != nil {
+		return false
+	}
+	return permissions[name]
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/search"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	searchResultLimit    = 1000 // most ranked matches a search hands to the item list
+	autocompleteLimit    = 10
+	searchIndexBatchSize = 500
+)
+
+// The item index is built on first use and shared by every request
+var (
+	itemIndex        atomic.Pointer[search.Index]
+	itemIndexBuildMu sync.Mutex
+)
+
+type SearchService interface {
+	Autocomplete(query string, limit int) ([]dtos.ItemSuggestion, error)
+}
+
+type searchService struct{}
+
+func NewSearchService() SearchService {
+	return &searchService{}
+}
+
+// searchSynonyms are the built-in groups plus those of SEARCH_SYNONYMS_FILE.
+func searchSynonyms() *search.Synonyms {
+	synonyms := search.NewSynonyms(search.DefaultSynonyms)
+	if path := os.Getenv("SEARCH_SYNONYMS_FILE"); path != "" {
+		if err := synonyms.LoadFile(path); err != nil {
+			log.Println("Failed to load search synonyms:", err)
+		}
+	}
+	return synonyms
+}
+
+// itemSearchIndex returns the item index, building it on first use.
+func itemSearchIndex() (*search.Index, error) {
+	if index := itemIndex.Load(); index != nil {
+		return index, nil
+	}
+
+	itemIndexBuildMu.Lock()
+	defer itemIndexBuildMu.Unlock()
+	if index := itemIndex.Load(); index != nil {
+		return index, nil
+	}
+
+	index := search.NewIndex(searchSynonyms())
+	if err := rebuildSearchIndex(index); err != nil {
+		return nil, err
+	}
+	itemIndex.Store(index)
+	return index, nil
+}
+
+func searchDocument(item models.Item) search.Document {
+	doc := search.Document{ID: item.ID, Name: item.Name}
+	if item.Description != nil {
+		doc.Description = *item.Description
+	}
+	if item.Category != nil {
+		doc.Category = *item.Category
+	}
+	if item.SKU != nil {
+		doc.Codes = append(doc.Codes, *item.SKU)
+	}
+	if item.Barcode != nil {
+		doc.Codes = append(doc.Codes, *item.Barcode)
+	}
+	return doc
+}
+
+func rebuildSearchIndex(index *search.Index) error {
+	return index.Rebuild(func() ([]search.Document, error) {
+		var docs []search.Document
+		var batch []models.Item
+		err := config.DB.Select("id", "name", "description", "category", "sku", "barcode").
+			FindInBatches(&batch, searchIndexBatchSize, func(tx *gorm.DB, _ int) error {
+				for _, item := range batch {
+					docs = append(docs, searchDocument(item))
+				}
+				return nil
+			}).Error
+		return docs, err
+	})
+}
+
+// refreshSearchIndex re-reads the given items into the index after they were
+// created, changed or deleted. Call it once the transaction has committed.
+func refreshSearchIndex(ids ...uint) {
+	index := itemIndex.Load()
+	if index == nil || len(ids) == 0 {
+		// Not built yet, the first search loads the current items
+		return
+	}
+
+	var items []models.Item
+	if err := config.DB.Select("id", "name", "description", "category", "sku", "barcode").
+		Where("id IN ?", ids).Find(&items).Error; err != nil {
+		log.Println("Failed to refresh search index:", err)
+		return
+	}
+
+	found := make(map[uint]bool, len(items))
+	for _, item := range items {
+		index.Put(searchDocument(item))
+		found[item.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			index.Remove(id)
+		}
+	}
+}
+
+// searchItemIDs returns the ids of the items matching the query, best match first.
+func searchItemIDs(query string) ([]uint, error) {
+	index, err := itemSearchIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	results := index.Search(query, searchResultLimit, true)
+	ids := make([]uint, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	return ids, nil
+}
+
+// Autocomplete suggests items while the cashier types, the last word may be unfinished.
+func (s *searchService) Autocomplete(query string, limit int) ([]dtos.ItemSuggestion, error) {
+	if limit < 1 || limit > 50 {
+		limit = autocompleteLimit
+	}
+
+	index, err := itemSearchIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	results := index.Search(query, limit, true)
+	suggestions := []dtos.ItemSuggestion{}
+	if len(results) == 0 {
+		return suggestions, nil
+	}
+
+	ids := make([]uint, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+
+	var items []models.Item
+	if err := config.DB.Preload("Components.Component").Where("id IN ?", ids).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if err := NewReservationService().ApplyAvailability(items); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Item, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	for _, result := range results {
+		item, ok := byID[result.ID]
+		if !ok {
+			continue
+		}
+		suggestions = append(suggestions, dtos.ItemSuggestion{
+			ID:           item.ID,
+			Name:         item.Name,
+			SKU:          item.SKU,
+			Barcode:      item.Barcode,
+			Price:        item.Price,
+			Available:    item.Available,
+			ThumbnailURL: item.ThumbnailURL,
+			IsKit:        item.IsKit,
+		})
+	}
+	return suggestions, nil
+}
+
+// StartSearchIndexRefresh rebuilds the item index periodically in the background,
+// picking up changes made outside this process.
+func StartSearchIndexRefresh(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			index := itemIndex.Load()
+			if index == nil {
+				continue
+			}
+			if err := rebuildSearchIndex(index); err != nil {
+				log.Println("Failed to rebuild search index:", err)
+			}
+		}
+	}()
+}
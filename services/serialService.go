@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SerialService interface {
+	RegisterSerials(tx *gorm.DB, itemID uint, serials []string, receiptID *uint) error
+	AssignSerials(tx *gorm.DB, itemID uint, transactionItemID uint, serials []string) error
+	MarkSold(tx *gorm.DB, item models.Item, transactionItem models.TransactionItem) error
+	ReturnSerials(tx *gorm.DB, transactionItemID uint) error
+	ReleaseSerials(tx *gorm.DB, transactionID uint) error
+	LookupSerial(serial string) (*dtos.SerialLookupResponse, error)
+}
+
+type serialService struct{}
+
+func NewSerialService() SerialService {
+	return &serialService{}
+}
+
+// normalizeSerials trims the serials and rejects blanks and duplicates.
+func normalizeSerials(serials []string) ([]string, error) {
+	seen := make(map[string]bool, len(serials))
+	result := make([]string, 0, len(serials))
+	for _, serial := range serials {
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			return nil, errors.New("serial number cannot be empty")
+		}
+		if seen[serial] {
+			return nil, fmt.Errorf("serial %s listed twice", serial)
+		}
+		seen[serial] = true
+		result = append(result, serial)
+	}
+	return result, nil
+}
+
+// RegisterSerials adds received units of a serialized item to stock.
+func (s *serialService) RegisterSerials(tx *gorm.DB, itemID uint, serials []string, receiptID *uint) error {
+	serials, err := normalizeSerials(serials)
+	if err != nil {
+		return err
+	}
+
+	for _, serial := range serials {
+		var existing models.SerialNumber
+		if err := tx.Where("item_id = ? AND serial = ?", itemID, serial).First(&existing).Error; err == nil {
+			return fmt.Errorf("serial %s already exists", serial)
+		}
+
+		unit := models.SerialNumber{
+			ItemID:         itemID,
+			Serial:         serial,
+			Status:         "in_stock",
+			GoodsReceiptID: receiptID,
+		}
+		if err := tx.Create(&unit).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AssignSerials links in-stock units to a transaction line (draft or sale).
+func (s *serialService) AssignSerials(tx *gorm.DB, itemID uint, transactionItemID uint, serials []string) error {
+	serials, err := normalizeSerials(serials)
+	if err != nil {
+		return err
+	}
+
+	for _, serial := range serials {
+		res := tx.Model(&models.SerialNumber{}).
+			Where("item_id = ? AND serial = ? AND status = ? AND transaction_item_id IS NULL", itemID, serial, "in_stock").
+			Update("transaction_item_id", transactionItemID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("serial %s is not available for item %d", serial, itemID)
+		}
+	}
+	return nil
+}
+
+// MarkSold requires exactly one assigned serial per unit of a serialized item
+// and flags them as sold.
+func (s *serialService) MarkSold(tx *gorm.DB, item models.Item, transactionItem models.TransactionItem) error {
+	if !item.IsSerialized {
+		return nil
+	}
+
+	var assigned int64
+	if err := tx.Model(&models.SerialNumber{}).
+		Where("transaction_item_id = ?", transactionItem.ID).
+		Count(&assigned).Error; err != nil {
+		return err
+	}
+	if int(assigned) != transactionItem.Quantity {
+		return fmt.Errorf("item '%s' needs %d serial number(s), got %d", item.Name, transactionItem.Quantity, assigned)
+	}
+
+	return tx.Model(&models.SerialNumber{}).
+		Where("transaction_item_id = ?", transactionItem.ID).
+		Updates(map[string]any{"status": "sold", "sold_at": time.Now()}).Error
+}
+
+// ReturnSerials puts refunded units back in stock.
+func (s *serialService) ReturnSerials(tx *gorm.DB, transactionItemID uint) error {
+	return tx.Model(&models.SerialNumber{}).
+		Where("transaction_item_id = ? AND status = ?", transactionItemID, "sold").
+		Updates(map[string]any{"status": "in_stock", "sold_at": nil, "transaction_item_id": nil}).Error
+}
+
+// ReleaseSerials frees the units assigned to a deleted draft.
+func (s *serialService) ReleaseSerials(tx *gorm.DB, transactionID uint) error {
+	return tx.Model(&models.SerialNumber{}).
+		Where("status = ? AND transaction_item_id IN (?)", "in_stock",
+			tx.Model(&models.TransactionItem{}).Select("id").Where("transaction_id = ?", transactionID)).
+		Update("transaction_item_id", nil).Error
+}
+
+func (s *serialService) LookupSerial(serial string) (*dtos.SerialLookupResponse, error) {
+	var unit models.SerialNumber
+	if err := config.DB.Unscoped().Preload("Item").
+		Where("serial = ?", strings.TrimSpace(serial)).
+		First(&unit).Error; err != nil {
+		return nil, errors.New("serial not found")
+	}
+
+	response := dtos.SerialLookupResponse{
+		Serial:         unit.Serial,
+		Status:         unit.Status,
+		ItemID:         unit.ItemID,
+		ItemName:       unit.Item.Name,
+		GoodsReceiptID: unit.GoodsReceiptID,
+		ReceivedAt:     unit.CreatedAt,
+		SoldAt:         unit.SoldAt,
+		WarrantyMonths: unit.Item.WarrantyMonths,
+	}
+
+	if unit.Status == "sold" && unit.TransactionItemID != nil {
+		var line models.TransactionItem
+		if err := config.DB.First(&line, *unit.TransactionItemID).Error; err == nil {
+			var transaction models.Transaction
+			if err := config.DB.First(&transaction, line.TransactionID).Error; err == nil {
+				response.TransactionID = &transaction.ID
+				response.CustomerName = transaction.CustomerName
+				response.CustomerPhone = transaction.CustomerPhone
+			}
+		}
+
+		if unit.SoldAt != nil && unit.Item.WarrantyMonths > 0 {
+			expires := unit.SoldAt.AddDate(0, unit.Item.WarrantyMonths, 0)
+			response.WarrantyExpiresAt = &expires
+			response.UnderWarranty = time.Now().Before(expires)
+		}
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// startSession opens a login session and returns its access and refresh tokens.
+// Sessions on a terminal are shorter and lock when left idle.
+func startSession(tx *gorm.DB, user models.User, clientIP string, userAgent string, terminalID *uint) (*dtos.AuthResponse, error) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		LastUsedAt:       now,
+		IPAddress:        clientIP,
+		UserAgent:        userAgent,
+		TerminalID:       terminalID,
+	}
+	session.ExpiresAt = now.Add(utils.RefreshTokenTTL())
+	if terminalID != nil {
+		session.ExpiresAt = now.Add(utils.TerminalSessionTTL())
+	}
+	if err := tx.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return sessionTokens(user, session, refreshToken)
+}
+
+// sessionIdle reports whether a terminal session is locked, or has been left alone
+// longer than the idle timeout and should be.
+func sessionIdle(session models.Session) bool {
+	if session.TerminalID == nil {
+		return false
+	}
+	return session.LockedAt != nil || time.Since(session.LastUsedAt) > utils.TerminalIdleTimeout()
+}
+
+func sessionTokens(user models.User, session models.Session, refreshToken string) (*dtos.AuthResponse, error) {
+	token, err := utils.GenerateToken(user.ID, user.Role, user.TokenVersion, session.ID)
+	if err != nil {
+		return nil, errors.New("Failed to generate token")
+	}
+
+	response := &dtos.AuthResponse{
+		Token:              token,
+		RefreshToken:       refreshToken,
+		ExpiresIn:          int(utils.AccessTokenTTL().Seconds()),
+		Role:               user.Role,
+		MustChangePassword: user.MustChangePassword,
+		TerminalID:         session.TerminalID,
+	}
+	// A PIN on a registered terminal already counts as a second factor
+	if session.TerminalID == nil && !user.TOTPEnabled {
+		required, err := NewRoleService().RequiresTwoFactor(user.Role)
+		if err != nil {
+			return nil, err
+		}
+		response.TwoFactorSetupRequired = required
+	}
+	return response, nil
+}
+
+// revokeSessions ends every open session of the user. Access tokens already handed
+// out stay valid until the token version is bumped as well.
+func revokeSessions(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeAllAccess ends every session and invalidates every access token of the user.
+func revokeAllAccess(tx *gorm.DB, userID uint) error {
+	if err := revokeSessions(tx, userID); err != nil {
+		return err
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StockTransferService interface {
+	CreateTransfer(input dtos.CreateStockTransferInput, actor common.Actor) (*models.StockTransfer, error)
+	ReceiveTransfer(id string, actor common.Actor) (*models.StockTransfer, error)
+	CancelTransfer(id string, actor common.Actor) (*models.StockTransfer, error)
+	GetTransfers(filter dtos.StockTransferFilter) (*dtos.StockTransferListResponse, error)
+	GetTransferByID(id string) (*models.StockTransfer, error)
+}
+
+type stockTransferService struct{}
+
+func NewStockTransferService() StockTransferService {
+	return &stockTransferService{}
+}
+
+// CreateTransfer takes the stock out of the source location right away.
+// Until the transfer is received the quantity is in transit and not on hand anywhere.
+func (s *stockTransferService) CreateTransfer(input dtos.CreateStockTransferInput, actor common.Actor) (*models.StockTransfer, error) {
+	if input.FromLocationID == input.ToLocationID {
+		return nil, errors.New("source and destination must differ")
+	}
+
+	var count int64
+	if err := config.DB.Model(&models.Location{}).
+		Where("id IN ?", []uint{input.FromLocationID, input.ToLocationID}).
+		Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count != 2 {
+		return nil, errors.New("location not found")
+	}
+
+	transfer := models.StockTransfer{
+		FromLocationID: input.FromLocationID,
+		ToLocationID:   input.ToLocationID,
+		Status:         "in_transit",
+		Note:           input.Note,
+		CreatedBy:      actor.UserID,
+		SentAt:         time.Now(),
+	}
+	// Lines for the same item are merged so each is checked against the level once,
+	// and kept in item order so concurrent transfers lock the levels in the same order
+	lines := map[uint]int{}
+	for _, i := range input.Items {
+		if _, seen := lines[i.ItemID]; !seen {
+			transfer.Items = append(transfer.Items, models.StockTransferItem{ItemID: i.ItemID})
+		}
+		lines[i.ItemID] += i.Quantity
+	}
+	for idx := range transfer.Items {
+		transfer.Items[idx].Quantity = lines[transfer.Items[idx].ItemID]
+	}
+	sort.Slice(transfer.Items, func(a, b int) bool { return transfer.Items[a].ItemID < transfer.Items[b].ItemID })
+
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		for _, i := range transfer.Items {
+			var level models.ItemStock
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("item_id = ? AND location_id = ?", i.ItemID, input.FromLocationID).
+				First(&level).Error; err != nil || level.Quantity < i.Quantity {
+				return fmt.Errorf("insufficient stock for item %d at source location", i.ItemID)
+			}
+		}
+
+		if err := tx.Create(&transfer).Error; err != nil {
+			return err
+		}
+
+		invService := NewInventoryService()
+		ref := fmt.Sprintf("TRF-%d", transfer.ID)
+		from := transfer.FromLocationID
+		for idx, i := range transfer.Items {
+			applied, err := invService.AdjustStock(tx, i.ItemID, from, -i.Quantity)
+			if err != nil {
+				return err
+			}
+			// Receiving or cancelling puts back the full quantity, so all of it must leave
+			if applied != -i.Quantity {
+				return fmt.Errorf("insufficient stock for item %d at source location", i.ItemID)
+			}
+
+			// The batches travel with the stock, first-expiry-first-out like a sale
+			allocations, err := NewLotService().ConsumeLots(tx, i.ItemID, from, i.Quantity, nil)
+			if err != nil {
+				return err
+			}
+			for _, allocation := range allocations {
+				lot := models.StockTransferItemLot{StockTransferItemID: i.ID, LotID: allocation.LotID, Quantity: allocation.Quantity}
+				if err := tx.Create(&lot).Error; err != nil {
+					return err
+				}
+				transfer.Items[idx].Lots = append(transfer.Items[idx].Lots, lot)
+			}
+
+			if err := logStockChangeByLot(tx, i.ItemID, applied, allocations, "transfer_out", ref, actor.UserID, "Sent to another location", &from); err != nil {
+				return err
+			}
+		}
+
+		description := fmt.Sprintf("Stock transfer %s created", ref)
+		return log.CreateAuditLog(tx, "stock_transfer", "create", transfer.ID, nil, transfer, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTransferByID(fmt.Sprint(transfer.ID))
+}
+
+func (s *stockTransferService) ReceiveTransfer(id string, actor common.Actor) (*models.StockTransfer, error) {
+	return s.closeTransfer(id, actor, "received")
+}
+
+// CancelTransfer returns in-transit stock to the source location.
+func (s *stockTransferService) CancelTransfer(id string, actor common.Actor) (*models.StockTransfer, error) {
+	return s.closeTransfer(id, actor, "cancelled")
+}
+
+func (s *stockTransferService) closeTransfer(id string, actor common.Actor, status string) (*models.StockTransfer, error) {
+	var transfer models.StockTransfer
+	if err := config.DB.Preload("Items.Lots").First(&transfer, id).Error; err != nil {
+		return nil, errors.New("transfer not found")
+	}
+
+	if transfer.Status != "in_transit" {
+		return nil, errors.New("transfer is not in transit")
+	}
+
+	locationID := transfer.ToLocationID
+	note := "Received from another location"
+	if status == "cancelled" {
+		locationID = transfer.FromLocationID
+		note = "Transfer cancelled, returned to source"
+	}
+
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		oldTransfer := transfer
+		now := time.Now()
+		res := tx.Model(&models.StockTransfer{}).
+			Where("id = ? AND status = ?", transfer.ID, "in_transit").
+			Updates(map[string]any{"status": status, "received_by": actor.UserID, "received_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errors.New("transfer is not in transit")
+		}
+
+		invService := NewInventoryService()
+		ref := fmt.Sprintf("TRF-%d", transfer.ID)
+		for _, i := range transfer.Items {
+			applied, err := invService.AdjustStock(tx, i.ItemID, locationID, i.Quantity)
+			if err != nil {
+				return err
+			}
+
+			// Received batches join the same batch at the destination, cancelled ones
+			// go back to the lot they left
+			var allocations []models.TransactionItemLot
+			for _, sent := range i.Lots {
+				lot, err := NewLotService().ReceiveLot(tx, sent.LotID, locationID, sent.Quantity)
+				if err != nil {
+					return err
+				}
+				allocations = append(allocations, models.TransactionItemLot{LotID: lot.ID, Quantity: sent.Quantity})
+			}
+
+			if err := logStockChangeByLot(tx, i.ItemID, applied, allocations, "transfer_in", ref, actor.UserID, note, &locationID); err != nil {
+				return err
+			}
+		}
+
+		transfer.Status = status
+		transfer.ReceivedBy = actor.UserID
+		transfer.ReceivedAt = &now
+		description := fmt.Sprintf("Stock transfer %s %s", ref, status)
+		return log.CreateAuditLog(tx, "stock_transfer", "status_change", transfer.ID, oldTransfer, transfer, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetTransferByID(id)
+}
+
+func (s *stockTransferService) GetTransfers(filter dtos.StockTransferFilter) (*dtos.StockTransferListResponse, error) {
+	var transfers []models.StockTransfer
+	var total int64
+
+	db := config.DB.Model(&models.StockTransfer{})
+
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.LocationID != 0 {
+		db = db.Where("from_location_id = ? OR to_location_id = ?", filter.LocationID, filter.LocationID)
+	}
+
+	if err := db.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.Limit < 1 {
+		filter.Limit = 10
+	}
+	offset := (filter.Page - 1) * filter.Limit
+
+	if err := db.Preload("Items.Item", withDeleted).Preload("FromLocation").Preload("ToLocation").
+		Order("created_at DESC").
+		Limit(filter.Limit).
+		Offset(offset).
+		Find(&transfers).Error; err != nil {
+		return nil, err
+	}
+
+	return &dtos.StockTransferListResponse{
+		Data:       transfers,
+		Page:       filter.Page,
+		Limit:      filter.Limit,
+		Total:      total,
+		TotalPages: int((total + int64(filter.Limit) - 1) / int64(filter.Limit)),
+	}, nil
+}
+
+func (s *stockTransferService) GetTransferByID(id string) (*models.StockTransfer, error) {
+	var transfer models.StockTransfer
+	if err := config.DB.Preload("Items.Item", withDeleted).Preload("FromLocation").Preload("ToLocation").
+		First(&transfer, id).Error; err != nil {
+		return nil, errors.New("transfer not found")
+	}
+	return &transfer, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+
+	"gorm.io/gorm"
+)
+
+type SupplierService interface {
+	GetSuppliers() ([]models.Supplier, error)
+	CreateSupplier(input dtos.CreateSupplierInput, actor common.Actor) (*models.Supplier, error)
+	UpdateSupplier(id string, input dtos.CreateSupplierInput, actor common.Actor) (*models.Supplier, error)
+}
+
+type supplierService struct{}
+
+func NewSupplierService() SupplierService {
+	return &supplierService{}
+}
+
+func (s *supplierService) GetSuppliers() ([]models.Supplier, error) {
+	var suppliers []models.Supplier
+	if err := config.DB.Order("name").Find(&suppliers).Error; err != nil {
+		return nil, err
+	}
+	return suppliers, nil
+}
+
+func (s *supplierService) CreateSupplier(input dtos.CreateSupplierInput, actor common.Actor) (*models.Supplier, error) {
+	var existing models.Supplier
+	if err := config.DB.Where("name = ?", input.Name).First(&existing).Error; err == nil {
+		return nil, errors.New("supplier already exists")
+	}
+
+	supplier := models.Supplier{
+		Name:         input.Name,
+		Phone:        input.Phone,
+		Email:        input.Email,
+		LeadTimeDays: input.LeadTimeDays,
+	}
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&supplier).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Supplier '%s' created", supplier.Name)
+		return log.CreateAuditLog(tx, "supplier", "create", supplier.ID, nil, supplier, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+func (s *supplierService) UpdateSupplier(id string, input dtos.CreateSupplierInput, actor common.Actor) (*models.Supplier, error) {
+	var supplier models.Supplier
+	if err := config.DB.First(&supplier, id).Error; err != nil {
+		return nil, errors.New("supplier not found")
+	}
+
+	var existing models.Supplier
+	if err := config.DB.Where("name = ? AND id != ?", input.Name, supplier.ID).First(&existing).Error; err == nil {
+		return nil, errors.New("supplier already exists")
+	}
+
+	oldSupplier := supplier
+	supplier.Name = input.Name
+	supplier.Phone = input.Phone
+	supplier.Email = input.Email
+	supplier.LeadTimeDays = input.LeadTimeDays
+
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&supplier).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Supplier '%s' updated", supplier.Name)
+		return log.CreateAuditLog(tx, "supplier", "update", supplier.ID, oldSupplier, supplier, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TerminalService interface {
+	GetTerminals() ([]models.Terminal, error)
+	RegisterTerminal(input dtos.RegisterTerminalInput, actor common.Actor) (*dtos.TerminalRegistration, error)
+	RevokeTerminal(id string, actor common.Actor) error
+}
+
+type terminalService struct{}
+
+func NewTerminalService() TerminalService {
+	return &terminalService{}
+}
+
+func (s *terminalService) GetTerminals() ([]models.Terminal, error) {
+	var terminals []models.Terminal
+	if err := config.DB.Order("id").Find(&terminals).Error; err != nil {
+		return nil, err
+	}
+	return terminals, nil
+}
+
+// RegisterTerminal issues a device token for a new terminal. The token is
+// returned once and only its hash is kept.
+func (s *terminalService) RegisterTerminal(input dtos.RegisterTerminalInput, actor common.Actor) (*dtos.TerminalRegistration, error) {
+	token, err := newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	terminal := models.Terminal{
+		Name:      strings.TrimSpace(input.Name),
+		TokenHash: hashRefreshToken(token),
+		CreatedBy: actor.UserID,
+	}
+	err = log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&terminal).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Terminal '%s' registered", terminal.Name)
+		return log.CreateAuditLog(tx, "terminal", "create", terminal.ID, nil, terminal, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.TerminalRegistration{Terminal: terminal, DeviceToken: token}, nil
+}
+
+// RevokeTerminal stops the device token from working and ends the sessions opened
+// on the terminal.
+func (s *terminalService) RevokeTerminal(id string, actor common.Actor) error {
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var terminal models.Terminal
+		if err := tx.First(&terminal, id).Error; err != nil {
+			return errors.New("Terminal not found")
+		}
+		if terminal.RevokedAt != nil {
+			return errors.New("terminal is already revoked")
+		}
+		oldTerminal := terminal
+
+		now := time.Now()
+		terminal.RevokedAt = &now
+		if err := tx.Model(&terminal).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Session{}).
+			Where("terminal_id = ? AND revoked_at IS NULL", terminal.ID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Terminal '%s' revoked", terminal.Name)
+		return log.CreateAuditLog(tx, "terminal", "update", terminal.ID, oldTerminal, terminal, nil, actor, description)
+	})
+}
+
+// terminalByToken finds the registered, not revoked terminal of a device token.
+func terminalByToken(token string) (*models.Terminal, error) {
+	if token == "" {
+		return nil, errors.New("Terminal is not registered")
+	}
+	var terminal models.Terminal
+	if err := config.DB.Where("token_hash = ? AND revoked_at IS NULL", hashRefreshToken(token)).
+		First(&terminal).Error; err != nil {
+		return nil, errors.New("Terminal is not registered")
+	}
+	return &terminal, nil
+}
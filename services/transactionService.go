@@ -8,19 +8,23 @@ import (
 	"kd-api/config"
 	"kd-api/dtos"
 	"kd-api/models"
+	"kd-api/utils/common"
 	"kd-api/utils/log"
+	"kd-api/utils/permission"
+	"kd-api/utils/response"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransactionService interface {
-	CreateTransaction(input dtos.CreateTransactionInput, userID *uint, clientIP string) (*models.Transaction, []string, error)
-	UpdateTransactionStatus(id string, input dtos.UpdateTransactionInput, userID *uint, clientIP string) (*models.Transaction, error)
-	GetTransactions(filter dtos.TransactionFilter) (*dtos.TransactionListResponse, error)
-	GetTransactionHistory(filter dtos.TransactionFilter) (*dtos.TransactionListResponse, error)
+	CreateTransaction(input dtos.CreateTransactionInput, actor common.Actor) (*models.Transaction, []string, error)
+	UpdateTransactionStatus(id string, input dtos.UpdateTransactionInput, actor common.Actor, role string) (*models.Transaction, error)
+	GetTransactions(filter dtos.TransactionFilter, role string) (*dtos.TransactionListResponse, error)
+	GetTransactionHistory(filter dtos.TransactionFilter, role string) (*dtos.TransactionListResponse, error)
 	GetTransactionByID(id string) (*models.Transaction, error)
-	DeleteDraft(id string, userID *uint, clientIP string) error
-	RefundTransaction(id string, userID *uint, clientIP string) (*models.Transaction, error)
+	DeleteDraft(id string, actor common.Actor, role string) error
+	RefundTransaction(id string, actor common.Actor, role string) (*models.Transaction, error)
 }
 
 type transactionService struct{}
@@ -29,7 +33,7 @@ func NewTransactionService() TransactionService {
 	return &transactionService{}
 }
 
-func (s *transactionService) CreateTransaction(input dtos.CreateTransactionInput, userID *uint, clientIP string) (*models.Transaction, []string, error) {
+func (s *transactionService) CreateTransaction(input dtos.CreateTransactionInput, actor common.Actor) (*models.Transaction, []string, error) {
 	if len(input.Items) == 0 {
 		return nil, nil, errors.New("no items provided")
 	}
@@ -41,7 +45,7 @@ func (s *transactionService) CreateTransaction(input dtos.CreateTransactionInput
 	var transaction models.Transaction
 	var warnings []string
 
-	err := config.DB.Transaction(func(tx *gorm.DB) error {
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
 		var total float64
 		var transactionItems []models.TransactionItem
 		var localWarnings []string
@@ -89,12 +93,21 @@ func (s *transactionService) CreateTransaction(input dtos.CreateTransactionInput
 			Items:           transactionItems,
 			Note:            input.Note,
 			TransactionType: "onsite",
+			CustomerName:    input.CustomerName,
+			CustomerPhone:   input.CustomerPhone,
+			TerminalID:      input.TerminalID,
 		}
 
 		if input.TransactionType != nil && *input.TransactionType != "" {
 			transaction.TransactionType = *input.TransactionType
 		}
 
+		locationID, err := resolveLocationID(tx, input.LocationID, actor.UserID)
+		if err != nil {
+			return err
+		}
+		transaction.LocationID = &locationID
+
 		if input.Status == "completed" {
 			if input.PaymentAmount == nil || *input.PaymentAmount < finalTotal {
 				return errors.New("payment not enough")
@@ -110,49 +123,36 @@ func (s *transactionService) CreateTransaction(input dtos.CreateTransactionInput
 				defaultType := "cash"
 				transaction.PaymentType = &defaultType
 			}
-
-			for _, tItem := range transactionItems {
-				var item models.Item
-				if err := tx.First(&item, tItem.ItemID).Error; err != nil {
-					return err
-				}
-
-				if item.Stock < tItem.Quantity {
-					localWarnings = append(localWarnings,
-						fmt.Sprintf(
-							"Warning: Item '%s' stock insufficient (current: %d, required: %d)",
-							item.Name, item.Stock, tItem.Quantity,
-						),
-					)
-					item.Stock = 0
-				} else {
-					item.Stock -= tItem.Quantity
-				}
-
-				if err := tx.Save(&item).Error; err != nil {
-					return err
-				}
-			}
 		}
 
 		if err := tx.Create(&transaction).Error; err != nil {
 			return err
 		}
 
-		// Inventory Ledger: Log Sales
+		// Serial numbers picked at the counter, drafts hold them as well
+		serialService := NewSerialService()
+		for idx, i := range input.Items {
+			if len(i.Serials) == 0 {
+				continue
+			}
+			if err := serialService.AssignSerials(tx, i.ItemID, transaction.Items[idx].ID, i.Serials); err != nil {
+				return err
+			}
+		}
+
+		// Inventory Ledger: Log Sales, or hold stock for the draft
 		if input.Status == "completed" {
-			invService := NewInventoryService()
-			for _, tItem := range transactionItems {
-				// We need the ID, but we already have item.Stock updated.
-				// Change is negative.
-				change := -tItem.Quantity
-				ref := fmt.Sprintf("TX-%d", transaction.ID)
-				note := "Sold in transaction"
-				
-				if err := invService.LogStockChange(tx, tItem.ItemID, change, "sale", ref, userID, note); err != nil {
-					return err
-				}
+			saleWarnings, err := applySale(tx, &transaction, actor.UserID)
+			if err != nil {
+				return err
 			}
+			localWarnings = append(localWarnings, saleWarnings...)
+		} else {
+			reserveWarnings, err := NewReservationService().ReserveForTransaction(tx, &transaction)
+			if err != nil {
+				return err
+			}
+			localWarnings = append(localWarnings, reserveWarnings...)
 		}
 
 		description := fmt.Sprintf("Transaction #%d created", transaction.ID)
@@ -162,8 +162,7 @@ func (s *transactionService) CreateTransaction(input dtos.CreateTransactionInput
 			transaction.ID,
 			nil,
 			&transaction,
-			userID,
-			clientIP,
+			actor,
 			description,
 		); err != nil {
 			return err
@@ -177,77 +176,101 @@ func (s *transactionService) CreateTransaction(input dtos.CreateTransactionInput
 		return nil, nil, err
 	}
 
-	if err := config.DB.Preload("Items.Item").First(&transaction, transaction.ID).Error; err != nil {
+	if err := config.DB.Preload("Items.Item", withDeleted).First(&transaction, transaction.ID).Error; err != nil {
 		return nil, nil, err
 	}
 
 	return &transaction, warnings, nil
 }
 
-func (s *transactionService) UpdateTransactionStatus(id string, input dtos.UpdateTransactionInput, userID *uint, clientIP string) (*models.Transaction, error) {
+// UpdateTransactionStatus edits a transaction and moves it along the status
+// machine in transactionTransitions, running the stock effects of the move.
+// Discount and type can only change on drafts, the note at any time.
+func (s *transactionService) UpdateTransactionStatus(id string, input dtos.UpdateTransactionInput, actor common.Actor, role string) (*models.Transaction, error) {
 	var transaction models.Transaction
-	if err := config.DB.Preload("Items").First(&transaction, id).Error; err != nil {
-		return nil, errors.New("transaction not found")
-	}
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&transaction, id).Error; err != nil {
+			return errors.New("transaction not found")
+		}
 
-	oldCopy := transaction
+		oldCopy := transaction
 
-	if input.Status != "" {
-		if input.Status != "draft" && input.Status != "completed" {
-			return nil, errors.New("invalid status")
+		if input.Status != "" {
+			if err := checkTransactionTransition(oldCopy.Status, input.Status, role); err != nil {
+				return err
+			}
+			transaction.Status = input.Status
 		}
-		transaction.Status = input.Status
-	}
 
-	if input.Note != nil {
-		transaction.Note = input.Note
-	}
+		if oldCopy.Status != "draft" && (input.TransactionType != nil || input.Discount != nil) {
+			return &TransactionStatusError{
+				Code:    TransactionErrLocked,
+				Message: fmt.Sprintf("a %s transaction can no longer be edited, only its note", oldCopy.Status),
+			}
+		}
 
-	if input.TransactionType != nil {
-		transaction.TransactionType = *input.TransactionType
-	}
+		if input.Note != nil {
+			transaction.Note = input.Note
+		}
 
-	if input.Discount != nil {
-		if *input.Discount < 0 {
-			transaction.Discount = 0
-		} else {
-			transaction.Discount = *input.Discount
+		if input.TransactionType != nil {
+			transaction.TransactionType = *input.TransactionType
 		}
 
-		var total float64
-		for _, item := range transaction.Items {
-			total += item.Subtotal
+		if input.Discount != nil {
+			if *input.Discount < 0 {
+				transaction.Discount = 0
+			} else {
+				transaction.Discount = *input.Discount
+			}
+
+			var total float64
+			for _, item := range transaction.Items {
+				total += item.Subtotal
+			}
+
+			finalTotal := total - transaction.Discount
+			if finalTotal < 0 {
+				finalTotal = 0
+			}
+			transaction.Total = finalTotal
 		}
 
-		finalTotal := total - transaction.Discount
-		if finalTotal < 0 {
-			finalTotal = 0
+		switch {
+		case oldCopy.Status == "draft" && transaction.Status == "completed":
+			// A draft being completed turns its reservations into a sale
+			if _, err := applySale(tx, &transaction, actor.UserID); err != nil {
+				return err
+			}
+		case oldCopy.Status == "completed" && transaction.Status == "refunded":
+			if err := applyRefund(tx, &transaction, actor.UserID); err != nil {
+				return err
+			}
 		}
-		transaction.Total = finalTotal
-	}
 
-	if err := config.DB.Save(&transaction).Error; err != nil {
-		return nil, err
-	}
+		if err := tx.Omit("Items").Save(&transaction).Error; err != nil {
+			return err
+		}
 
-	description := fmt.Sprintf("Transaction #%d updated", transaction.ID)
-	if err := log.CreateTransactionAuditLog(
-		config.DB,
-		"update",
-		transaction.ID,
-		&oldCopy,
-		&transaction,
-		userID,
-		clientIP,
-		description,
-	); err != nil {
-		return nil, errors.New("failed to create audit log")
+		action := "update"
+		description := fmt.Sprintf("Transaction #%d updated", transaction.ID)
+		if oldCopy.Status != transaction.Status {
+			action = "status_change"
+			description = fmt.Sprintf("Transaction #%d changed from %s to %s", transaction.ID, oldCopy.Status, transaction.Status)
+		}
+		if oldCopy.Status != "refunded" && transaction.Status == "refunded" {
+			action = "refund"
+		}
+		return log.CreateTransactionAuditLog(tx, action, transaction.ID, &oldCopy, &transaction, actor, description)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return &transaction, nil
 }
 
-func (s *transactionService) GetTransactions(filter dtos.TransactionFilter) (*dtos.TransactionListResponse, error) {
+func (s *transactionService) GetTransactions(filter dtos.TransactionFilter, role string) (*dtos.TransactionListResponse, error) {
 	var transactions []models.Transaction
 	var total int64
 
@@ -275,7 +298,7 @@ func (s *transactionService) GetTransactions(filter dtos.TransactionFilter) (*dt
 	}
 	offset := (filter.Page - 1) * filter.Limit
 
-	if err := db.Preload("Items.Item").
+	if err := db.Preload("Items.Item", withDeleted).
 		Order("created_at DESC").
 		Limit(filter.Limit).
 		Offset(offset).
@@ -284,7 +307,7 @@ func (s *transactionService) GetTransactions(filter dtos.TransactionFilter) (*dt
 	}
 
 	return &dtos.TransactionListResponse{
-		Data:       transactions,
+		Data:       response.FilterTransactions(transactions, roleCan(role, permission.ItemsViewCost)),
 		Page:       filter.Page,
 		Limit:      filter.Limit,
 		Total:      total,
@@ -292,7 +315,7 @@ func (s *transactionService) GetTransactions(filter dtos.TransactionFilter) (*dt
 	}, nil
 }
 
-func (s *transactionService) GetTransactionHistory(filter dtos.TransactionFilter) (*dtos.TransactionListResponse, error) {
+func (s *transactionService) GetTransactionHistory(filter dtos.TransactionFilter, role string) (*dtos.TransactionListResponse, error) {
 	var transactions []models.Transaction
 	var total int64
 
@@ -324,7 +347,7 @@ func (s *transactionService) GetTransactionHistory(filter dtos.TransactionFilter
 	}
 	offset := (filter.Page - 1) * filter.Limit
 
-	if err := db.Preload("Items.Item").
+	if err := db.Preload("Items.Item", withDeleted).
 		Order("created_at DESC").
 		Limit(filter.Limit).
 		Offset(offset).
@@ -333,7 +356,7 @@ func (s *transactionService) GetTransactionHistory(filter dtos.TransactionFilter
 	}
 
 	return &dtos.TransactionListResponse{
-		Data:       transactions,
+		Data:       response.FilterTransactions(transactions, roleCan(role, permission.ItemsViewCost)),
 		Page:       filter.Page,
 		Limit:      filter.Limit,
 		Total:      total,
@@ -343,76 +366,198 @@ func (s *transactionService) GetTransactionHistory(filter dtos.TransactionFilter
 
 func (s *transactionService) GetTransactionByID(id string) (*models.Transaction, error) {
 	var transaction models.Transaction
-	if err := config.DB.Preload("Items.Item").First(&transaction, id).Error; err != nil {
+	if err := config.DB.Preload("Items.Item", withDeleted).Preload("Items.Lots.Lot").Preload("Items.Serials").First(&transaction, id).Error; err != nil {
 		return nil, errors.New("transaction not found")
 	}
 	return &transaction, nil
 }
 
-func (s *transactionService) DeleteDraft(id string, userID *uint, clientIP string) error {
+func (s *transactionService) DeleteDraft(id string, actor common.Actor, role string) error {
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var transaction models.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transaction, id).Error; err != nil {
+			return errors.New("transaction not found")
+		}
+
+		if err := checkTransactionTransition(transaction.Status, transactionDeleted, role); err != nil {
+			return err
+		}
+
+		txCopy := transaction
+
+		if err := NewReservationService().ReleaseForTransaction(tx, transaction.ID, "released"); err != nil {
+			return err
+		}
+		if err := NewSerialService().ReleaseSerials(tx, transaction.ID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&transaction).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Transaction #%d deleted", txCopy.ID)
+		return log.CreateTransactionAuditLog(tx, "delete", txCopy.ID, &txCopy, nil, actor, description)
+	})
+}
+
+// RefundTransaction moves a completed transaction to refunded, returning its
+// units to stock.
+func (s *transactionService) RefundTransaction(id string, actor common.Actor, role string) (*models.Transaction, error) {
 	var transaction models.Transaction
-	if err := config.DB.First(&transaction, id).Error; err != nil {
-		return errors.New("transaction not found")
-	}
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items.Item", withDeleted).First(&transaction, id).Error; err != nil {
+			return errors.New("transaction not found")
+		}
 
-	if transaction.Status != "draft" {
-		return errors.New("only draft can be deleted")
-	}
+		if transaction.Status == "refunded" {
+			return &TransactionStatusError{Code: TransactionErrIllegalTransition, Message: "transaction is already refunded"}
+		}
+		if err := checkTransactionTransition(transaction.Status, "refunded", role); err != nil {
+			return err
+		}
 
-	txCopy := transaction
+		oldCopy := transaction
+		if err := applyRefund(tx, &transaction, actor.UserID); err != nil {
+			return err
+		}
+		transaction.Status = "refunded"
+
+		if err := tx.Omit("Items").Save(&transaction).Error; err != nil {
+			return err
+		}
 
-	if err := config.DB.Delete(&transaction).Error; err != nil {
-		return errors.New("failed to delete")
+		description := fmt.Sprintf("Transaction #%d refunded", transaction.ID)
+		return log.CreateTransactionAuditLog(tx, "refund", transaction.ID, &oldCopy, &transaction, actor, description)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	description := fmt.Sprintf("Transaction #%d deleted", txCopy.ID)
-	_ = log.CreateTransactionAuditLog(
-		config.DB,
-		"delete",
-		txCopy.ID,
-		&txCopy,
-		nil,
-		userID,
-		clientIP,
-		description,
-	)
-
-	return nil
+	return &transaction, nil
 }
 
-func (s *transactionService) RefundTransaction(id string, userID *uint, clientIP string) (*models.Transaction, error) {
-	var transaction models.Transaction
-	if err := config.DB.Preload("Items.Item").First(&transaction, id).Error; err != nil {
-		return nil, errors.New("transaction not found")
+// applySale takes the sold quantities out of the transaction's location and writes
+// the "sale" ledger entries. Reservations held by the transaction are consumed first
+// so they do not count against its own sale; a sale that would take stock reserved
+// by other drafts is refused.
+func applySale(tx *gorm.DB, transaction *models.Transaction, userID *uint) ([]string, error) {
+	var warnings []string
+
+	locationID, err := resolveLocationID(tx, transaction.LocationID, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	if transaction.Status != "completed" {
-		return nil, errors.New("only completed transactions can be refunded")
+	reservations := NewReservationService()
+	if err := reservations.ReleaseForTransaction(tx, transaction.ID, "consumed"); err != nil {
+		return nil, err
 	}
 
-	for _, tItem := range transaction.Items {
+	invService := NewInventoryService()
+	costing := NewCostingService()
+	ref := fmt.Sprintf("TX-%d", transaction.ID)
+	for idx, tItem := range transaction.Items {
 		var item models.Item
-		if err := config.DB.First(&item, tItem.ItemID).Error; err == nil {
-			item.Stock += tItem.Quantity
-			config.DB.Save(&item)
+		if err := tx.First(&item, tItem.ItemID).Error; err != nil {
+			return nil, err
+		}
 
-			// Inventory Log (Refund)
-			invService := NewInventoryService()
-			change := tItem.Quantity // Refund is positive (stock returns)
-			ref := fmt.Sprintf("TX-%d (REFUND)", transaction.ID)
-			note := "Refunded transaction"
+		if err := NewSerialService().MarkSold(tx, item, tItem); err != nil {
+			return nil, err
+		}
 
-			invService.LogStockChange(config.DB, tItem.ItemID, change, "refund", ref, userID, note)
+		// A kit takes its stock from the components, one ledger entry each
+		lines, err := expandKit(tx, item, tItem.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		note := "Sold in transaction"
+		if item.IsKit {
+			note = fmt.Sprintf("Sold in kit '%s'", item.Name)
 		}
-	}
 
-	transaction.Status = "refunded"
-	transaction.Payment = nil
-	transaction.Change = nil
+		var totalCost float64
+		for _, line := range lines {
+			var level models.ItemStock
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("item_id = ? AND location_id = ?", line.Item.ID, locationID).
+				Find(&level).Error; err != nil {
+				return nil, err
+			}
 
-	if err := config.DB.Save(&transaction).Error; err != nil {
-		return nil, err
+			// The transaction's own reservations were consumed above, whatever is
+			// still reserved belongs to other drafts and cannot be sold here
+			reserved, err := reservations.ReservedQuantity(tx, line.Item.ID, &locationID)
+			if err != nil {
+				return nil, err
+			}
+			if reserved > 0 && level.Quantity-reserved < line.Quantity {
+				return nil, &TransactionStatusError{
+					Code: TransactionErrStockReserved,
+					Message: fmt.Sprintf(
+						"item '%s' is reserved for other drafts (available: %d, required: %d)",
+						line.Item.Name, max(level.Quantity-reserved, 0), line.Quantity,
+					),
+				}
+			}
+
+			if level.Quantity < line.Quantity {
+				warnings = append(warnings,
+					fmt.Sprintf(
+						"Warning: Item '%s' stock insufficient (current: %d, required: %d)",
+						line.Item.Name, level.Quantity, line.Quantity,
+					),
+				)
+			}
+
+			// Snapshot the cost so later buy price changes do not rewrite profit
+			unitCost, err := costing.CostOfSale(tx, line.Item.ID, line.Quantity)
+			if err != nil {
+				return nil, err
+			}
+			totalCost += unitCost * float64(line.Quantity)
+
+			// Kept so a refund returns these components even if the kit changes
+			if item.IsKit {
+				component := models.TransactionItemComponent{
+					TransactionItemID: tItem.ID,
+					ItemID:            line.Item.ID,
+					Quantity:          line.Quantity,
+					UnitCost:          unitCost,
+				}
+				if err := tx.Create(&component).Error; err != nil {
+					return nil, err
+				}
+			}
+
+			// Change is negative
+			change, err := invService.AdjustStock(tx, line.Item.ID, locationID, -line.Quantity)
+			if err != nil {
+				return nil, err
+			}
+
+			// First-expiry-first-out across the item's lots
+			transactionItemID := tItem.ID
+			allocations, err := NewLotService().ConsumeLots(tx, line.Item.ID, locationID, -change, &transactionItemID)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := logStockChangeByLot(tx, line.Item.ID, change, allocations, "sale", ref, userID, note, &locationID); err != nil {
+				return nil, err
+			}
+		}
+
+		unitCost := 0.0
+		if tItem.Quantity != 0 {
+			unitCost = totalCost / float64(tItem.Quantity)
+		}
+		if err := tx.Model(&models.TransactionItem{}).Where("id = ?", tItem.ID).
+			Update("unit_cost", unitCost).Error; err != nil {
+			return nil, err
+		}
+		transaction.Items[idx].UnitCost = unitCost
 	}
 
-	return &transaction, nil
+	return warnings, nil
 }
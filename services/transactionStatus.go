@@ -0,0 +1,140 @@
+package services
+
+import (
+	"fmt"
+	"kd-api/models"
+	"kd-api/utils/permission"
+
+	"gorm.io/gorm"
+)
+
+// Error codes sent with a rejected status change or edit, so clients can tell the
+// cases apart without parsing the message.
+const (
+	TransactionErrInvalidStatus     = "INVALID_STATUS"
+	TransactionErrIllegalTransition = "ILLEGAL_TRANSITION"
+	TransactionErrPermissionDenied  = "PERMISSION_DENIED"
+	TransactionErrLocked            = "TRANSACTION_LOCKED"
+	TransactionErrStockReserved     = "STOCK_RESERVED"
+)
+
+// TransactionStatusError is returned when a transaction cannot move to a status,
+// can no longer be edited, or cannot be sold from the stock that is left.
+type TransactionStatusError struct {
+	Code    string
+	Message string
+}
+
+func (e *TransactionStatusError) Error() string {
+	return e.Message
+}
+
+// transactionDeleted is not a stored status, deleting a draft is the transition to it.
+const transactionDeleted = "deleted"
+
+// transactionTransitions lists the statuses each status may move to and the
+// permission each move needs. Moving to the same status is not a transition.
+//
+//	draft -> completed     reservations become a sale, stock leaves the location
+//	draft -> deleted       reservations and serial numbers are released
+//	completed -> refunded  sold units, lots and serial numbers return to stock
+//
+// Refunded is final, and a completed sale never goes back to draft.
+var transactionTransitions = map[string]map[string]string{
+	"draft": {
+		"completed":        permission.TransactionsUpdateStatus,
+		transactionDeleted: permission.TransactionsDelete,
+	},
+	"completed": {
+		"refunded": permission.TransactionsRefund,
+	},
+	"refunded": {},
+}
+
+// checkTransactionTransition refuses unknown statuses, transitions the table does
+// not allow and roles without the transition's permission.
+func checkTransactionTransition(from string, to string, role string) error {
+	if _, known := transactionTransitions[to]; !known && to != transactionDeleted {
+		return &TransactionStatusError{
+			Code:    TransactionErrInvalidStatus,
+			Message: fmt.Sprintf("invalid status '%s', use draft, completed or refunded", to),
+		}
+	}
+	if from == to {
+		return nil
+	}
+
+	required, allowed := transactionTransitions[from][to]
+	if !allowed {
+		message := fmt.Sprintf("a %s transaction cannot be changed to %s", from, to)
+		switch to {
+		case transactionDeleted:
+			message = "only draft transactions can be deleted"
+		case "refunded":
+			message = "only completed transactions can be refunded"
+		}
+		return &TransactionStatusError{Code: TransactionErrIllegalTransition, Message: message}
+	}
+
+	if !roleCan(role, required) {
+		return &TransactionStatusError{
+			Code:    TransactionErrPermissionDenied,
+			Message: fmt.Sprintf("permission '%s' is required to change a %s transaction to %s", required, from, to),
+		}
+	}
+	return nil
+}
+
+// applyRefund returns the sold units of a completed transaction to stock, at the
+// cost they were sold for, together with their lots and serial numbers.
+func applyRefund(tx *gorm.DB, transaction *models.Transaction, userID *uint) error {
+	locationID, err := resolveLocationID(tx, transaction.LocationID, nil)
+	if err != nil {
+		return err
+	}
+
+	invService := NewInventoryService()
+	ref := fmt.Sprintf("TX-%d (REFUND)", transaction.ID)
+	note := "Refunded transaction"
+
+	for _, tItem := range transaction.Items {
+		var item models.Item
+		if err := tx.First(&item, tItem.ItemID).Error; err != nil {
+			// Items deleted since the sale do not get stock back
+			continue
+		}
+
+		allocations, err := NewLotService().ReturnLots(tx, tItem.ID, locationID)
+		if err != nil {
+			return err
+		}
+		if err := NewSerialService().ReturnSerials(tx, tItem.ID); err != nil {
+			return err
+		}
+
+		// Kits return the components they were sold with
+		lines, err := soldStockLines(tx, item, tItem)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			// Returned units go back at the cost they were sold for
+			if err := NewCostingService().ReturnToStock(tx, line.Item.ID, line.Quantity, line.UnitCost, fmt.Sprintf("TX-%d", transaction.ID)); err != nil {
+				return err
+			}
+
+			// Inventory Log (Refund), stock returns so the change is positive
+			change, err := invService.AdjustStock(tx, line.Item.ID, locationID, line.Quantity)
+			if err != nil {
+				return err
+			}
+			if err := logStockChangeByLot(tx, line.Item.ID, change, allocationsForItem(tx, allocations, line.Item.ID), "refund", ref, userID, note, &locationID); err != nil {
+				return err
+			}
+		}
+	}
+
+	transaction.Payment = nil
+	transaction.Change = nil
+	return nil
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const recoveryCodeCount = 10
+
+type TwoFactorService interface {
+	Setup(userID uint, input dtos.TwoFactorSetupInput) (*dtos.TwoFactorSetupResponse, error)
+	Enable(userID uint, input dtos.TwoFactorCodeInput, actor common.Actor) (*dtos.RecoveryCodesResponse, error)
+	Disable(userID uint, input dtos.DisableTwoFactorInput, actor common.Actor) error
+	RegenerateRecoveryCodes(userID uint, input dtos.TwoFactorCodeInput, actor common.Actor) (*dtos.RecoveryCodesResponse, error)
+	Reset(id string, actor common.Actor) error
+}
+
+type twoFactorService struct{}
+
+func NewTwoFactorService() TwoFactorService {
+	return &twoFactorService{}
+}
+
+// normalizeRecoveryCode accepts codes typed with or without the dash, in any case.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// replaceRecoveryCodes drops the user's old codes and returns fresh ones, formatted
+// xxxxx-xxxxx. Only their hashes are stored.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(buf))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+
+		if err := tx.Create(&models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: hashRefreshToken(normalizeRecoveryCode(code)),
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// checkSecondFactor accepts a current authenticator code not used before, or an
+// unused recovery code, which is then spent. The user row should be locked.
+func checkSecondFactor(tx *gorm.DB, user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if !user.TOTPEnabled || user.TOTPSecret == nil {
+		return false, nil
+	}
+
+	if len(code) == utils.TOTPDigits {
+		step, ok := utils.VerifyTOTP(*user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+		if !ok {
+			return false, nil
+		}
+		user.TOTPLastStep = step
+		return true, tx.Model(user).Update("totp_last_step", step).Error
+	}
+
+	var recovery models.RecoveryCode
+	err := tx.Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRefreshToken(normalizeRecoveryCode(code))).
+		First(&recovery).Error
+	if err == gorm.ErrRecordNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Model(&recovery).Update("used_at", time.Now()).Error
+}
+
+// Setup creates a new secret for the user. It only takes effect once Enable
+// confirms that the authenticator produces the right codes.
+func (s *twoFactorService) Setup(userID uint, input dtos.TwoFactorSetupInput) (*dtos.TwoFactorSetupResponse, error) {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return nil, errors.New("User not found")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+		return nil, errors.New("Current password is incorrect")
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := config.DB.Model(&user).Update("totp_secret", secret).Error; err != nil {
+		return nil, err
+	}
+
+	return &dtos.TwoFactorSetupResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(utils.TOTPIssuer(), user.Username, secret),
+	}, nil
+}
+
+// Enable turns two-factor authentication on once a code from the new secret checks
+// out, and hands out the recovery codes.
+func (s *twoFactorService) Enable(userID uint, input dtos.TwoFactorCodeInput, actor common.Actor) (*dtos.RecoveryCodesResponse, error) {
+	var codes []string
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return errors.New("User not found")
+		}
+		if user.TOTPEnabled {
+			return errors.New("two-factor authentication is already enabled")
+		}
+		if user.TOTPSecret == nil {
+			return errors.New("two-factor authentication has not been set up")
+		}
+		oldUser := user
+
+		step, ok := utils.VerifyTOTP(*user.TOTPSecret, strings.TrimSpace(input.Code), time.Now(), 0)
+		if !ok {
+			return errors.New("Invalid two-factor code")
+		}
+
+		now := time.Now()
+		user.TOTPEnabled = true
+		user.TOTPEnabledAt = &now
+		user.TOTPLastStep = step
+		if err := tx.Model(&user).Updates(map[string]any{
+			"totp_enabled":    true,
+			"totp_enabled_at": now,
+			"totp_last_step":  step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("User '%s' enabled two-factor authentication", user.Username)
+		return log.CreateUserAuditLog(tx, "update", user.ID, &oldUser, &user, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// clearTwoFactor turns two-factor authentication off and drops the recovery codes.
+func clearTwoFactor(tx *gorm.DB, user *models.User) error {
+	user.TOTPSecret = nil
+	user.TOTPEnabled = false
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	if err := tx.Model(user).Updates(map[string]any{
+		"totp_secret":     nil,
+		"totp_enabled":    false,
+		"totp_enabled_at": nil,
+		"totp_last_step":  0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+}
+
+// Disable turns two-factor authentication off, confirmed with the password and a
+// code. Users whose role requires it cannot.
+func (s *twoFactorService) Disable(userID uint, input dtos.DisableTwoFactorInput, actor common.Actor) error {
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return errors.New("User not found")
+		}
+		if !user.TOTPEnabled {
+			return errors.New("two-factor authentication is not enabled")
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.CurrentPassword)); err != nil {
+			return errors.New("Current password is incorrect")
+		}
+
+		required, err := NewRoleService().RequiresTwoFactor(user.Role)
+		if err != nil {
+			return err
+		}
+		if required {
+			return errors.New("two-factor authentication is required for your role")
+		}
+
+		oldUser := user
+		ok, err := checkSecondFactor(tx, &user, input.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("Invalid two-factor code")
+		}
+
+		if err := clearTwoFactor(tx, &user); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("User '%s' disabled two-factor authentication", user.Username)
+		return log.CreateUserAuditLog(tx, "update", user.ID, &oldUser, &user, actor, description)
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, for when they ran out or
+// may have been seen.
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uint, input dtos.TwoFactorCodeInput, actor common.Actor) (*dtos.RecoveryCodesResponse, error) {
+	var codes []string
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return errors.New("User not found")
+		}
+		if !user.TOTPEnabled {
+			return errors.New("two-factor authentication is not enabled")
+		}
+
+		ok, err := checkSecondFactor(tx, &user, input.Code)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("Invalid two-factor code")
+		}
+
+		if codes, err = replaceRecoveryCodes(tx, user.ID); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("User '%s' generated new recovery codes", user.Username)
+		return log.CreateUserAuditLog(tx, "update", user.ID, nil, nil, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// Reset turns two-factor authentication off for a user who lost their
+// authenticator and recovery codes. If their role requires it they set it up again
+// on their next login.
+func (s *twoFactorService) Reset(id string, actor common.Actor) error {
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			return errors.New("User not found")
+		}
+		oldUser := user
+
+		if err := clearTwoFactor(tx, &user); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Two-factor authentication of user '%s' reset", user.Username)
+		return log.CreateUserAuditLog(tx, "update", user.ID, &oldUser, &user, actor, description)
+	})
+}
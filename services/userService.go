@@ -0,0 +1,341 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"kd-api/config"
+	"kd-api/dtos"
+	"kd-api/models"
+	"kd-api/utils"
+	"kd-api/utils/common"
+	"kd-api/utils/log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type UserService interface {
+	GetUsers() ([]models.User, error)
+	CreateUser(input dtos.CreateUserInput, actor common.Actor) (*models.User, error)
+	UpdateUser(id string, input dtos.UpdateUserInput, actor common.Actor) (*models.User, error)
+	SetUserActive(id string, active bool, actor common.Actor) (*models.User, error)
+	ResetPassword(id string, input dtos.ResetPasswordInput, actor common.Actor) error
+	GetSessions(id string) ([]models.Session, error)
+	KillSessions(id string, actor common.Actor) error
+	ClearPin(id string, actor common.Actor) error
+}
+
+type userService struct{}
+
+func NewUserService() UserService {
+	return &userService{}
+}
+
+func (s *userService) GetUsers() ([]models.User, error) {
+	var users []models.User
+	if err := config.DB.Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func usernameTaken(db *gorm.DB, username string, excludeID uint) bool {
+	var count int64
+	db.Model(&models.User{}).Where("username = ? AND id != ?", username, excludeID).Count(&count)
+	return count > 0
+}
+
+// ensureAnotherAdmin refuses changes that would leave no active admin to manage users.
+func ensureAnotherAdmin(tx *gorm.DB, user models.User) error {
+	if user.Role != "admin" || !user.IsActive {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.User{}).
+		Where("role = ? AND is_active = ? AND id != ?", "admin", true, user.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errors.New("at least one active admin is required")
+	}
+	return nil
+}
+
+// setPassword checks the new password against the policy and the recent passwords,
+// keeps the old hash in the history and bumps the token version so tokens issued
+// before the change stop working.
+func setPassword(tx *gorm.DB, user *models.User, password string, mustChange bool) error {
+	policy := utils.GetPasswordPolicy()
+	if err := policy.Validate(password); err != nil {
+		return err
+	}
+
+	if policy.History > 0 {
+		recent := []string{user.Password}
+		var history []models.PasswordHistory
+		if err := tx.Where("user_id = ?", user.ID).
+			Order("created_at DESC, id DESC").
+			Limit(policy.History - 1).
+			Find(&history).Error; err != nil {
+			return err
+		}
+		for _, entry := range history {
+			recent = append(recent, entry.Hash)
+		}
+		for _, hash := range recent {
+			if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+				return fmt.Errorf("password was used recently, choose one not among the last %d", policy.History)
+			}
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if user.Password != "" {
+		if err := tx.Create(&models.PasswordHistory{UserID: user.ID, Hash: user.Password}).Error; err != nil {
+			return err
+		}
+	}
+
+	// Sessions opened with the old password end with it
+	if err := revokeSessions(tx, user.ID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.Password = string(hash)
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = mustChange
+	user.TokenVersion++
+	return tx.Model(user).Updates(map[string]any{
+		"password":             user.Password,
+		"password_changed_at":  user.PasswordChangedAt,
+		"must_change_password": user.MustChangePassword,
+		"token_version":        user.TokenVersion,
+	}).Error
+}
+
+func (s *userService) CreateUser(input dtos.CreateUserInput, actor common.Actor) (*models.User, error) {
+	username := strings.TrimSpace(input.Username)
+	if usernameTaken(config.DB, username, 0) {
+		return nil, errors.New("username already exists")
+	}
+
+	if !roleExists(config.DB, input.Role) {
+		return nil, errors.New("role not found")
+	}
+
+	policy := utils.GetPasswordPolicy()
+	if err := policy.Validate(input.Password); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Username:           username,
+		Password:           string(hash),
+		Role:               input.Role,
+		IsActive:           true,
+		MustChangePassword: policy.ForceChange,
+	}
+
+	err = log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("User '%s' created with role %s", user.Username, user.Role)
+		return log.CreateUserAuditLog(tx, "create", user.ID, nil, &user, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (s *userService) UpdateUser(id string, input dtos.UpdateUserInput, actor common.Actor) (*models.User, error) {
+	var user models.User
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			return errors.New("User not found")
+		}
+		oldUser := user
+
+		if input.Username != nil {
+			username := strings.TrimSpace(*input.Username)
+			if usernameTaken(tx, username, user.ID) {
+				return errors.New("username already exists")
+			}
+			user.Username = username
+		}
+		if input.Role != nil && *input.Role != user.Role {
+			if !roleExists(tx, *input.Role) {
+				return errors.New("role not found")
+			}
+			if err := ensureAnotherAdmin(tx, oldUser); err != nil {
+				return err
+			}
+			user.Role = *input.Role
+		}
+
+		if err := tx.Model(&user).Updates(map[string]any{
+			"username": user.Username,
+			"role":     user.Role,
+		}).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("User '%s' updated", user.Username)
+		if oldUser.Role != user.Role {
+			description = fmt.Sprintf("User '%s' role changed from %s to %s", user.Username, oldUser.Role, user.Role)
+		}
+		return log.CreateUserAuditLog(tx, "update", user.ID, &oldUser, &user, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// SetUserActive disables or re-enables an account. Admins cannot disable themselves.
+func (s *userService) SetUserActive(id string, active bool, actor common.Actor) (*models.User, error) {
+	var user models.User
+	err := log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			return errors.New("User not found")
+		}
+		if user.IsActive == active {
+			return nil
+		}
+		if !active {
+			if actor.UserID != nil && *actor.UserID == user.ID {
+				return errors.New("you cannot disable your own account")
+			}
+			if err := ensureAnotherAdmin(tx, user); err != nil {
+				return err
+			}
+		}
+		oldUser := user
+
+		user.IsActive = active
+		user.DisabledAt = nil
+		if !active {
+			now := time.Now()
+			user.DisabledAt = &now
+			if err := revokeSessions(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&user).Updates(map[string]any{
+			"is_active":   user.IsActive,
+			"disabled_at": user.DisabledAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("User '%s' enabled", user.Username)
+		if !active {
+			description = fmt.Sprintf("User '%s' disabled", user.Username)
+		}
+		return log.CreateUserAuditLog(tx, "status_change", user.ID, &oldUser, &user, actor, description)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ResetPassword sets a new password chosen by an admin. When the policy forces it, the
+// user has to replace it at the next login.
+func (s *userService) ResetPassword(id string, input dtos.ResetPasswordInput, actor common.Actor) error {
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error; err != nil {
+			return errors.New("User not found")
+		}
+		oldUser := user
+
+		if err := setPassword(tx, &user, input.Password, utils.GetPasswordPolicy().ForceChange); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("Password of user '%s' reset", user.Username)
+		return log.CreateUserAuditLog(tx, "update", user.ID, &oldUser, &user, actor, description)
+	})
+}
+
+// GetSessions lists the user's open sessions, most recently used first.
+func (s *userService) GetSessions(id string) ([]models.Session, error) {
+	var user models.User
+	if err := config.DB.First(&user, id).Error; err != nil {
+		return nil, errors.New("User not found")
+	}
+
+	var sessions []models.Session
+	if err := config.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", user.ID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// KillSessions signs the user out everywhere: open sessions are revoked and access
+// tokens already issued stop working.
+func (s *userService) KillSessions(id string, actor common.Actor) error {
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return errors.New("User not found")
+		}
+		if err := revokeAllAccess(tx, user.ID); err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("All sessions of user '%s' revoked", user.Username)
+		return log.CreateUserAuditLog(tx, "update", user.ID, nil, nil, actor, description)
+	})
+}
+
+// ClearPin removes a forgotten PIN and ends the user's terminal sessions. The user
+// sets a new one with their password.
+func (s *userService) ClearPin(id string, actor common.Actor) error {
+	return log.WithActor(config.DB, actor).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return errors.New("User not found")
+		}
+		oldUser := user
+
+		user.PinHash = nil
+		user.PinSetAt = nil
+		if err := tx.Model(&user).Updates(map[string]any{
+			"pin_hash":   nil,
+			"pin_set_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND terminal_id IS NOT NULL AND revoked_at IS NULL", user.ID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		description := fmt.Sprintf("PIN of user '%s' cleared", user.Username)
+		return log.CreateUserAuditLog(tx, "update", user.ID, &oldUser, &user, actor, description)
+	})
+}
@@ -14,6 +14,14 @@ func GetUserRole(c *gin.Context) string {
 	return role.(string)
 }
 
+// HasPermission reports whether the role of the request's user grants the
+// permission. Requests without a logged in user have none.
+func HasPermission(c *gin.Context, name string) bool {
+	value, _ := c.Get("permissions")
+	permissions, ok := value.(map[string]bool)
+	return ok && permissions[name]
+}
+
 func GetUserID(c *gin.Context) *uint {
 	keys := []string{"user_id", "userID", "id", "uid", "userId"} // hardcoded
 	
@@ -34,6 +42,57 @@ func GetUserID(c *gin.Context) *uint {
 	return nil
 }
 
+// GetSessionID returns the login session of the request's token, 0 for tokens
+// issued before sessions existed.
+func GetSessionID(c *gin.Context) uint {
+	if value, exists := c.Get("sessionID"); exists {
+		if id, ok := value.(uint); ok {
+			return id
+		}
+	}
+	return 0
+}
+
+// GetTerminalID returns the terminal of a PIN session, nil for other sessions.
+func GetTerminalID(c *gin.Context) *uint {
+	if value, exists := c.Get("terminalID"); exists {
+		if id, ok := value.(uint); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// GetAPIKeyID returns the API key a request authenticated with, nil for user
+// sessions.
+func GetAPIKeyID(c *gin.Context) *uint {
+	if value, exists := c.Get("apiKeyID"); exists {
+		if id, ok := value.(uint); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// Actor is who made a change, as recorded in audit logs. Changes made by the
+// system itself, such as scheduled jobs, have an empty actor.
+type Actor struct {
+	UserID     *uint
+	IPAddress  string
+	TerminalID *uint // set for changes made from a PIN session
+	APIKeyID   *uint // set for changes made by an integration
+}
+
+// GetActor returns the user, address, terminal and API key of the request.
+func GetActor(c *gin.Context) Actor {
+	return Actor{
+		UserID:     GetUserID(c),
+		IPAddress:  c.ClientIP(),
+		TerminalID: GetTerminalID(c),
+		APIKeyID:   GetAPIKeyID(c),
+	}
+}
+
 func ToJSONString(v interface{}) *string {
 	if v == nil {
 		return nil
@@ -3,14 +3,18 @@ package utils
 import (
 	"errors"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
+	UserID       uint   `json:"user_id"`
+	Role         string `json:"role"`
+	TokenVersion uint   `json:"ver"`           // must match the user's TokenVersion
+	SessionID    uint   `json:"sid,omitempty"` // login session, revoked on logout
+	Purpose      string `json:"pur,omitempty"` // empty for access tokens, "2fa" for a login waiting for its code
 	jwt.RegisteredClaims
 }
 
@@ -22,17 +26,38 @@ func getJWTKey() ([]byte, error) {
 	return []byte(secret), nil
 }
 
-func GenerateToken(userID uint, role string) (string, error) {
+// AccessTokenTTL reads ACCESS_TOKEN_TTL_MINUTES, default 15. Clients renew access
+// tokens with their refresh token.
+func AccessTokenTTL() time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_TTL_MINUTES"))
+	if err != nil || minutes < 1 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// RefreshTokenTTL reads REFRESH_TOKEN_TTL_DAYS, default 7.
+func RefreshTokenTTL() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_TTL_DAYS"))
+	if err != nil || days < 1 {
+		days = 7
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func GenerateToken(userID uint, role string, tokenVersion uint, sessionID uint) (string, error) {
 	jwtKey, err := getJWTKey()
 	if err != nil {
 		return "", err
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
+	expirationTime := time.Now().Add(AccessTokenTTL())
 
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:       userID,
+		Role:         role,
+		TokenVersion: tokenVersion,
+		SessionID:    sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -65,7 +90,51 @@ func VerifyToken(tokenStr string) (*Claims, error) {
 	}
 
 	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
+	if !ok || !token.Valid || claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
+
+// Purpose of the token handed out between the password and the two-factor code
+const twoFactorPurpose = "2fa"
+
+// GenerateTwoFactorToken proves the password was checked, for five minutes. It is
+// exchanged with a TOTP or recovery code for a session and is no access token.
+func GenerateTwoFactorToken(userID uint, tokenVersion uint) (string, error) {
+	jwtKey, err := getJWTKey()
+	if err != nil {
+		return "", err
+	}
+
+	claims := &Claims{
+		UserID:       userID,
+		TokenVersion: tokenVersion,
+		Purpose:      twoFactorPurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+}
+
+func VerifyTwoFactorToken(tokenStr string) (*Claims, error) {
+	jwtKey, err := getJWTKey()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtKey, nil
+	})
+	if err != nil || !token.Valid || claims.Purpose != twoFactorPurpose {
 		return nil, errors.New("invalid token")
 	}
 
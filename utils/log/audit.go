@@ -15,20 +15,23 @@ func CreateAuditLog(
 	oldValue any,
 	newValue any,
 	changes *string,
-	userID *uint,
-	ipAddress string,
+	actor common.Actor,
 	description string,
 ) error {
 	auditLog := models.AuditLog{
 		EntityType:  entityType,
 		EntityID:    entityID,
 		Action:      action,
-		UserID:      userID,
+		UserID:      actor.UserID,
 		OldValue:    common.ToJSONString(oldValue),
 		NewValue:    common.ToJSONString(newValue),
 		Changes:     changes,
-		IPAddress:   &ipAddress,
+		TerminalID:  actor.TerminalID,
+		APIKeyID:    actor.APIKeyID,
 		Description: description,
 	}
+	if actor.IPAddress != "" {
+		auditLog.IPAddress = &actor.IPAddress
+	}
 	return db.Create(&auditLog).Error
 }
@@ -0,0 +1,175 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"kd-api/models"
+	"kd-api/utils/common"
+
+	"gorm.io/gorm"
+)
+
+// Tables the audit hook leaves alone: the logs and ledgers themselves, entities
+// whose services write their own, more descriptive entries, and rows saved
+// together with such an entity, which its entry already lists.
+var auditHookSkipped = map[string]bool{
+	"audit_logs":      true,
+	"data_migrations": true,
+	"login_attempts":  true,
+	"inventory_logs":  true,
+	"price_histories": true,
+
+	"items":                   true,
+	"transactions":            true,
+	"users":                   true,
+	"roles":                   true,
+	"terminals":               true,
+	"api_keys":                true,
+	"cash_sessions":           true,
+	"attendances":             true,
+	"suppliers":               true,
+	"locations":               true,
+	"stock_transfers":         true,
+	"goods_receipts":          true,
+	"purchase_orders":         true,
+	"scheduled_price_changes": true,
+
+	"transaction_items":        true,
+	"role_permissions":         true,
+	"api_key_permissions":      true,
+	"stock_transfer_items":     true,
+	"stock_transfer_item_lots": true,
+	"goods_receipt_items":      true,
+	"purchase_order_items":     true,
+}
+
+// Columns touched on every request; updates of only these are not audited.
+var auditHookIgnoredColumns = map[string]bool{
+	"last_used_at": true,
+	"last_used_ip": true,
+	"locked_at":    true,
+}
+
+type actorContextKey struct{}
+
+// WithActor attaches who is making the changes to db, for the audit hook. Pass it
+// the db a transaction is started from so every statement in it carries the actor.
+func WithActor(db *gorm.DB, actor common.Actor) *gorm.DB {
+	return db.WithContext(context.WithValue(db.Statement.Context, actorContextKey{}, actor))
+}
+
+// RegisterAuditHooks records every create, update and delete of the other models
+// in the audit log, in the same database transaction, with the actor attached by
+// WithActor. Changes made without one, such as scheduled jobs, have no actor.
+func RegisterAuditHooks(db *gorm.DB) error {
+	if err := db.Callback().Create().After("gorm:create").Register("audit:create", auditHook("create")); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("audit:update", auditHook("update")); err != nil {
+		return err
+	}
+	return db.Callback().Delete().After("gorm:delete").Register("audit:delete", auditHook("delete"))
+}
+
+func auditHook(action string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		statement := db.Statement
+		if db.Error != nil || db.RowsAffected == 0 || statement.Schema == nil || auditHookSkipped[statement.Schema.Table] {
+			return
+		}
+		entityType := db.NamingStrategy.ColumnName("", statement.Schema.Name)
+
+		// Updates and deletes by condition touch rows the statement never loaded,
+		// only their number is known
+		var changes *string
+		if values, ok := statement.Dest.(map[string]any); ok {
+			if onlyIgnoredColumns(values) {
+				return
+			}
+			changes = common.ToJSONString(values)
+		}
+
+		actor, _ := statement.Context.Value(actorContextKey{}).(common.Actor)
+		newEntry := func(id uint, description string) models.AuditLog {
+			entry := models.AuditLog{
+				EntityType:  entityType,
+				EntityID:    id,
+				Action:      action,
+				UserID:      actor.UserID,
+				Changes:     changes,
+				TerminalID:  actor.TerminalID,
+				APIKeyID:    actor.APIKeyID,
+				Description: description,
+			}
+			if actor.IPAddress != "" {
+				entry.IPAddress = &actor.IPAddress
+			}
+			return entry
+		}
+
+		var entries []models.AuditLog
+		eachAuditedRow(statement.ReflectValue, func(row reflect.Value) {
+			id := auditedRowID(db, row)
+			if id == 0 {
+				return
+			}
+			entry := newEntry(id, fmt.Sprintf("%s #%d %sd", entityType, id, action))
+			if action != "delete" {
+				entry.NewValue = common.ToJSONString(row.Interface())
+			}
+			entries = append(entries, entry)
+		})
+		if len(entries) == 0 {
+			entries = append(entries, newEntry(0, fmt.Sprintf("%d %s row(s) %sd", db.RowsAffected, entityType, action)))
+		}
+
+		if err := db.Session(&gorm.Session{NewDB: true}).Create(&entries).Error; err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+func onlyIgnoredColumns(values map[string]any) bool {
+	for column := range values {
+		if !auditHookIgnoredColumns[column] {
+			return false
+		}
+	}
+	return len(values) > 0
+}
+
+// eachAuditedRow calls fn with each struct of a single or batch statement.
+func eachAuditedRow(value reflect.Value, fn func(reflect.Value)) {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if row := reflect.Indirect(value.Index(i)); row.Kind() == reflect.Struct {
+				fn(row)
+			}
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
+
+func auditedRowID(db *gorm.DB, row reflect.Value) uint {
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field == nil {
+		return 0
+	}
+	value, zero := field.ValueOf(db.Statement.Context, row)
+	if zero {
+		return 0
+	}
+	id := reflect.ValueOf(value)
+	switch {
+	case id.CanUint():
+		return uint(id.Uint())
+	case id.CanInt():
+		return uint(id.Int())
+	}
+	return 0
+}
@@ -69,8 +69,7 @@ func CreateItemAuditLog(
 	action string,
 	itemID uint,
 	oldItem, newItem *models.Item,
-	userID *uint,
-	ipAddress string,
+	actor common.Actor,
 	description string,
 ) error {
 	var changes *string
@@ -86,8 +85,7 @@ func CreateItemAuditLog(
 		oldItem,
 		newItem,
 		changes,
-		userID,
-		ipAddress,
+		actor,
 		description,
 	)
 }
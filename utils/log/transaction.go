@@ -58,8 +58,7 @@ func CreateTransactionAuditLog(
 	action string,
 	txID uint,
 	oldTx, newTx *models.Transaction,
-	userID *uint,
-	ipAddress string,
+	actor common.Actor,
 	description string,
 ) error {
 	var changes *string
@@ -75,8 +74,7 @@ func CreateTransactionAuditLog(
 		oldTx,
 		newTx,
 		changes,
-		userID,
-		ipAddress,
+		actor,
 		description,
 	)
 }
@@ -0,0 +1,82 @@
+package log
+
+import (
+	"kd-api/models"
+	"kd-api/utils/common"
+
+	"gorm.io/gorm"
+)
+
+// CalculateUserChanges lists the changed fields. Passwords and PINs are never written
+// to the log, only the fact that one was changed.
+func CalculateUserChanges(oldUser, newUser *models.User) *string {
+	if oldUser == nil || newUser == nil {
+		return nil
+	}
+
+	changes := map[string]any{}
+
+	if oldUser.Username != newUser.Username {
+		changes["username"] = map[string]string{
+			"old": oldUser.Username,
+			"new": newUser.Username,
+		}
+	}
+
+	if oldUser.Role != newUser.Role {
+		changes["role"] = map[string]string{
+			"old": oldUser.Role,
+			"new": newUser.Role,
+		}
+	}
+
+	if oldUser.IsActive != newUser.IsActive {
+		changes["is_active"] = map[string]bool{
+			"old": oldUser.IsActive,
+			"new": newUser.IsActive,
+		}
+	}
+
+	if oldUser.TOTPEnabled != newUser.TOTPEnabled {
+		changes["totp_enabled"] = map[string]bool{
+			"old": oldUser.TOTPEnabled,
+			"new": newUser.TOTPEnabled,
+		}
+	}
+
+	if oldUser.Password != newUser.Password {
+		changes["password"] = "changed"
+	}
+
+	if (oldUser.PinHash == nil) != (newUser.PinHash == nil) ||
+		(oldUser.PinHash != nil && *oldUser.PinHash != *newUser.PinHash) {
+		changes["pin"] = "changed"
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	return common.ToJSONString(changes)
+}
+
+func CreateUserAuditLog(
+	db *gorm.DB,
+	action string,
+	targetID uint,
+	oldUser, newUser *models.User,
+	actor common.Actor,
+	description string,
+) error {
+	return CreateAuditLog(
+		db,
+		"user",
+		action,
+		targetID,
+		oldUser,
+		newUser,
+		CalculateUserChanges(oldUser, newUser),
+		actor,
+		description,
+	)
+}
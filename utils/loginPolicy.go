@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// LoginPolicy controls brute-force protection, read from the environment:
+// LOGIN_MAX_FAILURES failed logins per username (default 5) and
+// LOGIN_MAX_FAILURES_PER_IP per address (default 20) within LOGIN_WINDOW_MINUTES
+// (default 15) lock further attempts. The first lockout lasts
+// LOGIN_LOCKOUT_MINUTES (default 1) and doubles with every further failure up to
+// LOGIN_LOCKOUT_MAX_MINUTES (default 60).
+type LoginPolicy struct {
+	MaxFailures      int
+	MaxFailuresPerIP int
+	Window           time.Duration
+	Lockout          time.Duration
+	MaxLockout       time.Duration
+}
+
+func envInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+func GetLoginPolicy() LoginPolicy {
+	return LoginPolicy{
+		MaxFailures:      envInt("LOGIN_MAX_FAILURES", 5),
+		MaxFailuresPerIP: envInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+		Window:           time.Duration(envInt("LOGIN_WINDOW_MINUTES", 15)) * time.Minute,
+		Lockout:          time.Duration(envInt("LOGIN_LOCKOUT_MINUTES", 1)) * time.Minute,
+		MaxLockout:       time.Duration(envInt("LOGIN_LOCKOUT_MAX_MINUTES", 60)) * time.Minute,
+	}
+}
+
+// LockoutFor returns how long logins stay locked after the given number of failures,
+// zero below the limit.
+func (p LoginPolicy) LockoutFor(failures int, limit int) time.Duration {
+	if failures < limit {
+		return 0
+	}
+	lockout := p.Lockout
+	for i := limit; i < failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	return min(lockout, p.MaxLockout)
+}
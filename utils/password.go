@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"unicode"
+)
+
+// PasswordPolicy is read from the environment:
+// PASSWORD_MIN_LENGTH (default 8), PASSWORD_REQUIRE_DIGIT (default true),
+// PASSWORD_HISTORY, the number of recent passwords that cannot be reused (default 3),
+// and PASSWORD_FORCE_CHANGE, whether new and reset accounts must pick their own
+// password at the next login (default false).
+type PasswordPolicy struct {
+	MinLength    int
+	RequireDigit bool
+	History      int
+	ForceChange  bool
+}
+
+func GetPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{MinLength: 8, RequireDigit: true, History: 3}
+
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && n > 0 {
+		policy.MinLength = n
+	}
+	if b, err := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_DIGIT")); err == nil {
+		policy.RequireDigit = b
+	}
+	if n, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY")); err == nil && n >= 0 {
+		policy.History = n
+	}
+	if b, err := strconv.ParseBool(os.Getenv("PASSWORD_FORCE_CHANGE")); err == nil {
+		policy.ForceChange = b
+	}
+	return policy
+}
+
+// Validate checks the strength rules. Reuse is checked against the stored hashes
+// by the caller.
+func (p PasswordPolicy) Validate(password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+	if p.RequireDigit {
+		hasDigit, hasLetter := false, false
+		for _, r := range password {
+			hasDigit = hasDigit || unicode.IsDigit(r)
+			hasLetter = hasLetter || unicode.IsLetter(r)
+		}
+		if !hasDigit || !hasLetter {
+			return fmt.Errorf("password must contain letters and digits")
+		}
+	}
+	return nil
+}
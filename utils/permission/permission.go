@@ -0,0 +1,90 @@
+package permission
+
+// Named permissions checked by the routes and services. Roles stored in the database
+// are granted any subset of them.
+const (
+	ItemsCreate       = "items.create"
+	ItemsUpdate       = "items.update"
+	ItemsAdjustStock  = "items.adjust_stock" // change stock through PUT /items/:id
+	ItemsDelete       = "items.delete"       // move to and restore from the recycle bin
+	ItemsPurge        = "items.purge"
+	ItemsImport       = "items.import"
+	ItemsExport       = "items.export"
+	ItemsViewCost     = "items.view_cost" // buy prices, average cost and stock valuation
+	ItemsManagePrices = "items.manage_prices"
+
+	InventoryTransfer  = "inventory.transfer"
+	InventoryReceive   = "inventory.receive" // goods receipts
+	InventoryReconcile = "inventory.reconcile"
+	InventoryReorder   = "inventory.reorder" // reorder suggestions and purchase orders
+
+	LocationsManage = "locations.manage"
+	SuppliersManage = "suppliers.manage"
+
+	TransactionsCreate       = "transactions.create"
+	TransactionsUpdateStatus = "transactions.update_status"
+	TransactionsRefund       = "transactions.refund"
+	TransactionsDelete       = "transactions.delete" // delete drafts
+
+	ReportsView      = "reports.view"
+	AttendanceManage = "attendance.manage"
+	UsersManage      = "users.manage"
+	RolesManage      = "roles.manage"
+	TerminalsManage  = "terminals.manage"
+	APIKeysManage    = "api_keys.manage"
+	AuditView        = "audit.view"
+)
+
+type Definition struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Catalog lists every permission that can be granted.
+var Catalog = []Definition{
+	{ItemsCreate, "Create items, one by one or in bulk"},
+	{ItemsUpdate, "Edit item details and images"},
+	{ItemsAdjustStock, "Change item stock by hand"},
+	{ItemsDelete, "Delete items and restore them from the recycle bin"},
+	{ItemsPurge, "Permanently delete items from the recycle bin"},
+	{ItemsImport, "Import items from CSV or XLSX files, stock included"},
+	{ItemsExport, "Export items"},
+	{ItemsViewCost, "See buy prices, costs and stock valuation"},
+	{ItemsManagePrices, "Schedule and cancel price changes"},
+	{InventoryTransfer, "Create, receive and cancel stock transfers"},
+	{InventoryReceive, "Record goods receipts"},
+	{InventoryReconcile, "Reconcile stock"},
+	{InventoryReorder, "Review reorder suggestions and purchase orders"},
+	{LocationsManage, "Create and edit locations"},
+	{SuppliersManage, "Manage suppliers"},
+	{TransactionsCreate, "Create sales and drafts"},
+	{TransactionsUpdateStatus, "Change the status of transactions"},
+	{TransactionsRefund, "Refund transactions"},
+	{TransactionsDelete, "Delete draft transactions"},
+	{ReportsView, "View the dashboard and reports"},
+	{AttendanceManage, "Manage attendance"},
+	{UsersManage, "Manage user accounts"},
+	{RolesManage, "Manage roles and their permissions"},
+	{TerminalsManage, "Register and revoke POS terminals"},
+	{APIKeysManage, "Create and revoke API keys for integrations"},
+	{AuditView, "View audit logs"},
+}
+
+// Exists reports whether the permission is in the catalog.
+func Exists(name string) bool {
+	for _, definition := range Catalog {
+		if definition.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// All returns the names of every permission.
+func All() []string {
+	names := make([]string, len(Catalog))
+	for i, definition := range Catalog {
+		names[i] = definition.Name
+	}
+	return names
+}
@@ -2,19 +2,36 @@ package response
 
 import "kd-api/models"
 
-// Response khusus untuk role cashier (field dibatasi)
+// Response tanpa harga beli dan biaya, untuk user tanpa permission items.view_cost
 type ItemResponseCashier struct {
-	ID          uint    `json:"id"`
-	Name        string  `json:"name"`
-	Description *string `json:"description,omitempty"`
-	Stock       int     `json:"stock"`
-	Price       float64 `json:"price"`
-	ImageURL    *string `json:"image_url,omitempty"`
+	ID           uint    `json:"id"`
+	Name         string  `json:"name"`
+	SKU          *string `json:"sku,omitempty"`
+	Barcode      *string `json:"barcode,omitempty"`
+	Description  *string `json:"description,omitempty"`
+	Stock        int     `json:"stock"`
+	Reserved     int     `json:"reserved"`
+	Available    int     `json:"available"`
+	Price        float64 `json:"price"`
+	ImageURL     *string `json:"image_url,omitempty"`
+	ThumbnailURL *string `json:"thumbnail_url,omitempty"`
+
+	StockLevels []models.ItemStock `json:"stock_levels,omitempty"`
+
+	IsKit      bool                          `json:"is_kit"`
+	Components []KitComponentResponseCashier `json:"components,omitempty"`
+}
+
+// Komponen kit tanpa harga beli
+type KitComponentResponseCashier struct {
+	ItemID   uint   `json:"item_id"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
 }
 
-// Mapping slice item berdasarkan role user
-func FilterItemsForRole(items []models.Item, role string) interface{} {
-	if role != "cashier" {
+// Mapping slice item, field biaya hanya jika showCost (permission items.view_cost)
+func FilterItems(items []models.Item, showCost bool) interface{} {
+	if showCost {
 		return items
 	}
 
@@ -25,9 +42,9 @@ func FilterItemsForRole(items []models.Item, role string) interface{} {
 	return result
 }
 
-// Mapping single item berdasarkan role user
-func FilterItemForRole(item models.Item, role string) interface{} {
-	if role != "cashier" {
+// Mapping single item, field biaya hanya jika showCost (permission items.view_cost)
+func FilterItem(item models.Item, showCost bool) interface{} {
+	if showCost {
 		return item
 	}
 
@@ -36,12 +53,29 @@ func FilterItemForRole(item models.Item, role string) interface{} {
 
 // Internal helper, jangan dipakai langsung dari luar
 func mapItemForCashier(item models.Item) ItemResponseCashier {
+	var components []KitComponentResponseCashier
+	for _, c := range item.Components {
+		components = append(components, KitComponentResponseCashier{
+			ItemID:   c.ComponentID,
+			Name:     c.Component.Name,
+			Quantity: c.Quantity,
+		})
+	}
+
 	return ItemResponseCashier{
-		ID:          item.ID,
-		Name:        item.Name,
-		Description: item.Description,
-		Stock:       item.Stock,
-		Price:       item.Price,
-		ImageURL:    item.ImageURL,
+		ID:           item.ID,
+		Name:         item.Name,
+		SKU:          item.SKU,
+		Barcode:      item.Barcode,
+		Description:  item.Description,
+		Stock:        item.Stock,
+		Reserved:     item.Reserved,
+		Available:    item.Available,
+		Price:        item.Price,
+		ImageURL:     item.ImageURL,
+		ThumbnailURL: item.ThumbnailURL,
+		StockLevels:  item.StockLevels,
+		IsKit:        item.IsKit,
+		Components:   components,
 	}
 }
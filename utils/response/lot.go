@@ -0,0 +1,75 @@
+package response
+
+import (
+	"kd-api/dtos"
+	"kd-api/models"
+	"time"
+)
+
+// Lot tanpa harga beli item, untuk user tanpa permission items.view_cost
+type LotResponseCashier struct {
+	ID             uint       `json:"id"`
+	ItemID         uint       `json:"item_id"`
+	BatchNumber    string     `json:"batch_number"`
+	ExpiryDate     *time.Time `json:"expiry_date,omitempty"`
+	Quantity       int        `json:"quantity"`
+	Remaining      int        `json:"remaining"`
+	LocationID     uint       `json:"location_id"`
+	GoodsReceiptID *uint      `json:"goods_receipt_id,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	Item ItemResponseCashier `json:"item"`
+}
+
+// Lot kadaluarsa tanpa harga beli item
+type ExpiringLotResponseCashier struct {
+	LotResponseCashier
+	DaysLeft int  `json:"days_left"`
+	Expired  bool `json:"expired"`
+}
+
+// Mapping slice lot, field biaya item hanya jika showCost (permission items.view_cost)
+func FilterLots(lots []models.Lot, showCost bool) interface{} {
+	if showCost {
+		return lots
+	}
+
+	result := make([]LotResponseCashier, len(lots))
+	for i, lot := range lots {
+		result[i] = mapLotForCashier(lot)
+	}
+	return result
+}
+
+// Mapping slice lot kadaluarsa, field biaya item hanya jika showCost
+func FilterExpiringLots(lots []dtos.ExpiringLot, showCost bool) interface{} {
+	if showCost {
+		return lots
+	}
+
+	result := make([]ExpiringLotResponseCashier, len(lots))
+	for i, lot := range lots {
+		result[i] = ExpiringLotResponseCashier{
+			LotResponseCashier: mapLotForCashier(lot.Lot),
+			DaysLeft:           lot.DaysLeft,
+			Expired:            lot.Expired,
+		}
+	}
+	return result
+}
+
+// Internal helper, jangan dipakai langsung dari luar
+func mapLotForCashier(lot models.Lot) LotResponseCashier {
+	return LotResponseCashier{
+		ID:             lot.ID,
+		ItemID:         lot.ItemID,
+		BatchNumber:    lot.BatchNumber,
+		ExpiryDate:     lot.ExpiryDate,
+		Quantity:       lot.Quantity,
+		Remaining:      lot.Remaining,
+		LocationID:     lot.LocationID,
+		GoodsReceiptID: lot.GoodsReceiptID,
+		CreatedAt:      lot.CreatedAt,
+		Item:           mapItemForCashier(lot.Item),
+	}
+}
@@ -0,0 +1,123 @@
+package response
+
+import (
+	"kd-api/models"
+	"time"
+)
+
+// Transaksi tanpa biaya, untuk user tanpa permission items.view_cost
+type TransactionResponseCashier struct {
+	ID              uint                             `json:"id"`
+	Status          string                           `json:"status"`
+	Total           float64                          `json:"total"`
+	Discount        float64                          `json:"discount"`
+	Payment         *float64                         `json:"payment,omitempty"`
+	Change          *float64                         `json:"change,omitempty"`
+	PaymentType     *string                          `json:"payment_type,omitempty"`
+	Items           []TransactionItemResponseCashier `json:"items"`
+	Note            *string                          `json:"note,omitempty"`
+	TransactionType string                           `json:"transaction_type"`
+	LocationID      *uint                            `json:"location_id,omitempty"`
+	TerminalID      *uint                            `json:"terminal_id,omitempty"`
+	CustomerName    *string                          `json:"customer_name,omitempty"`
+	CustomerPhone   *string                          `json:"customer_phone,omitempty"`
+	CreatedAt       time.Time                        `json:"created_at"`
+	UpdatedAt       time.Time                        `json:"updated_at"`
+}
+
+// Item transaksi tanpa unit_cost
+type TransactionItemResponseCashier struct {
+	ID            uint    `json:"id"`
+	TransactionID uint    `json:"transaction_id"`
+	ItemID        uint    `json:"item_id"`
+	Quantity      int     `json:"quantity"`
+	Price         float64 `json:"price"`
+	Subtotal      float64 `json:"subtotal"`
+
+	Item    ItemResponseCashier                 `json:"item"`
+	Lots    []TransactionItemLotResponseCashier `json:"lots,omitempty"`
+	Serials []models.SerialNumber               `json:"serials,omitempty"`
+}
+
+// Lot yang terjual tanpa harga beli item
+type TransactionItemLotResponseCashier struct {
+	ID                uint                `json:"id"`
+	TransactionItemID uint                `json:"transaction_item_id"`
+	LotID             uint                `json:"lot_id"`
+	Quantity          int                 `json:"quantity"`
+	Lot               *LotResponseCashier `json:"lot,omitempty"`
+}
+
+// Mapping slice transaksi, field biaya hanya jika showCost (permission items.view_cost)
+func FilterTransactions(transactions []models.Transaction, showCost bool) interface{} {
+	if showCost {
+		return transactions
+	}
+
+	result := make([]TransactionResponseCashier, len(transactions))
+	for i, transaction := range transactions {
+		result[i] = mapTransactionForCashier(transaction)
+	}
+	return result
+}
+
+// Mapping single transaksi, field biaya hanya jika showCost (permission items.view_cost)
+func FilterTransaction(transaction models.Transaction, showCost bool) interface{} {
+	if showCost {
+		return transaction
+	}
+
+	return mapTransactionForCashier(transaction)
+}
+
+// Internal helper, jangan dipakai langsung dari luar
+func mapTransactionForCashier(transaction models.Transaction) TransactionResponseCashier {
+	items := make([]TransactionItemResponseCashier, len(transaction.Items))
+	for i, tItem := range transaction.Items {
+		var lots []TransactionItemLotResponseCashier
+		for _, l := range tItem.Lots {
+			lot := TransactionItemLotResponseCashier{
+				ID:                l.ID,
+				TransactionItemID: l.TransactionItemID,
+				LotID:             l.LotID,
+				Quantity:          l.Quantity,
+			}
+			if l.Lot != nil {
+				mapped := mapLotForCashier(*l.Lot)
+				lot.Lot = &mapped
+			}
+			lots = append(lots, lot)
+		}
+
+		items[i] = TransactionItemResponseCashier{
+			ID:            tItem.ID,
+			TransactionID: tItem.TransactionID,
+			ItemID:        tItem.ItemID,
+			Quantity:      tItem.Quantity,
+			Price:         tItem.Price,
+			Subtotal:      tItem.Subtotal,
+			Item:          mapItemForCashier(tItem.Item),
+			Lots:          lots,
+			Serials:       tItem.Serials,
+		}
+	}
+
+	return TransactionResponseCashier{
+		ID:              transaction.ID,
+		Status:          transaction.Status,
+		Total:           transaction.Total,
+		Discount:        transaction.Discount,
+		Payment:         transaction.Payment,
+		Change:          transaction.Change,
+		PaymentType:     transaction.PaymentType,
+		Items:           items,
+		Note:            transaction.Note,
+		TransactionType: transaction.TransactionType,
+		LocationID:      transaction.LocationID,
+		TerminalID:      transaction.TerminalID,
+		CustomerName:    transaction.CustomerName,
+		CustomerPhone:   transaction.CustomerPhone,
+		CreatedAt:       transaction.CreatedAt,
+		UpdatedAt:       transaction.UpdatedAt,
+	}
+}
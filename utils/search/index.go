@@ -0,0 +1,397 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Field weights, a hit in the name counts more than one in the description
+const (
+	weightName        = 3.0
+	weightCategory    = 1.5
+	weightDescription = 1.0
+
+	exactCodeScore  = 100.0 // query is the full SKU or barcode
+	prefixCodeScore = 20.0  // query starts a SKU or barcode
+
+	synonymFactor = 0.7 // a synonym counts less than the word itself
+	prefixFactor  = 0.7 // an unfinished word
+	typoFactor    = 0.5 // a word within edit distance
+)
+
+// Document is the searchable part of an item.
+type Document struct {
+	ID          uint
+	Name        string
+	Description string
+	Category    string
+	Codes       []string // SKU and barcode
+}
+
+type Result struct {
+	ID    uint
+	Score float64
+}
+
+type posting struct {
+	id     uint
+	weight float64 // field weight times term frequency
+}
+
+// change is a Put (doc set) or Remove (doc nil) made while a Rebuild was loading.
+type change struct {
+	seq uint64
+	doc *Document
+}
+
+// Index is an in-memory inverted index over item documents. It is safe for
+// concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string][]posting
+	docTerms map[uint][]string // terms per document, to unindex it
+	codes    map[string]uint   // normalized SKU/barcode -> document
+	docCodes map[uint][]string
+	synonyms *Synonyms
+
+	// Changes made during a Rebuild, newest per document, numbered by seq
+	seq      uint64
+	rebuilds int
+	changes  map[uint]change
+}
+
+func NewIndex(synonyms *Synonyms) *Index {
+	if synonyms == nil {
+		synonyms = NewSynonyms(nil)
+	}
+	return &Index{
+		postings: make(map[string][]posting),
+		docTerms: make(map[uint][]string),
+		codes:    make(map[string]uint),
+		docCodes: make(map[uint][]string),
+		synonyms: synonyms,
+	}
+}
+
+// Tokenize lowercases the text and splits it on anything but letters and digits.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// NormalizeCode makes "ab-12 34" and "AB1234" the same code.
+func NormalizeCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(code) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Len returns the number of indexed documents.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docTerms)
+}
+
+// Rebuild swaps the whole content of the index for the documents load returns.
+// load may read them before a Put or Remove that lands while it runs, so those
+// are applied again over its documents.
+func (idx *Index) Rebuild(load func() ([]Document, error)) error {
+	idx.mu.Lock()
+	idx.rebuilds++
+	since := idx.seq
+	idx.mu.Unlock()
+	defer func() {
+		idx.mu.Lock()
+		idx.rebuilds--
+		if idx.rebuilds == 0 {
+			idx.changes = nil
+		}
+		idx.mu.Unlock()
+	}()
+
+	docs, err := load()
+	if err != nil {
+		return err
+	}
+	fresh := NewIndex(idx.synonyms)
+	for _, doc := range docs {
+		fresh.add(doc)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for id, c := range idx.changes {
+		if c.seq <= since {
+			continue
+		}
+		fresh.remove(id)
+		if c.doc != nil {
+			fresh.add(*c.doc)
+		}
+	}
+	idx.postings = fresh.postings
+	idx.docTerms = fresh.docTerms
+	idx.codes = fresh.codes
+	idx.docCodes = fresh.docCodes
+	return nil
+}
+
+// Put adds the document or replaces its previous version.
+func (idx *Index) Put(doc Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.record(doc.ID, &doc)
+	idx.remove(doc.ID)
+	idx.add(doc)
+}
+
+func (idx *Index) Remove(id uint) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.record(id, nil)
+	idx.remove(id)
+}
+
+// record keeps the change for the rebuilds in progress.
+func (idx *Index) record(id uint, doc *Document) {
+	idx.seq++
+	if idx.rebuilds == 0 {
+		return
+	}
+	if idx.changes == nil {
+		idx.changes = make(map[uint]change)
+	}
+	idx.changes[id] = change{seq: idx.seq, doc: doc}
+}
+
+func (idx *Index) add(doc Document) {
+	weights := make(map[string]float64)
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{
+		{doc.Name, weightName},
+		{doc.Category, weightCategory},
+		{doc.Description, weightDescription},
+	} {
+		for _, term := range Tokenize(field.text) {
+			weights[term] += field.weight
+		}
+	}
+
+	terms := make([]string, 0, len(weights))
+	for term, weight := range weights {
+		idx.postings[term] = append(idx.postings[term], posting{id: doc.ID, weight: weight})
+		terms = append(terms, term)
+	}
+	idx.docTerms[doc.ID] = terms
+
+	for _, code := range doc.Codes {
+		if code = NormalizeCode(code); code != "" {
+			idx.codes[code] = doc.ID
+			idx.docCodes[doc.ID] = append(idx.docCodes[doc.ID], code)
+		}
+	}
+}
+
+func (idx *Index) remove(id uint) {
+	for _, term := range idx.docTerms[id] {
+		list := idx.postings[term]
+		for i, p := range list {
+			if p.id == id {
+				list = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(idx.postings, term)
+		} else {
+			idx.postings[term] = list
+		}
+	}
+	delete(idx.docTerms, id)
+
+	for _, code := range idx.docCodes[id] {
+		if idx.codes[code] == id {
+			delete(idx.codes, code)
+		}
+	}
+	delete(idx.docCodes, id)
+}
+
+// Search ranks the documents matching every word of the query, or the document
+// whose SKU or barcode is the query. Words match their synonyms, and words of at
+// least four letters also match terms one or two typos away. With prefix set the
+// last word may be unfinished, as typed in an autocomplete box. A limit of 0
+// returns every match.
+func (idx *Index) Search(query string, limit int, prefix bool) []Result {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	scores := make(map[uint]float64)
+	idx.scoreCodes(query, prefix, scores)
+
+	words := Tokenize(query)
+	if len(words) > 0 {
+		var matched map[uint]float64
+		for i, word := range words {
+			wordScores := idx.scoreWord(word, prefix && i == len(words)-1)
+			if matched == nil {
+				matched = wordScores
+				continue
+			}
+			for id := range matched {
+				if score, ok := wordScores[id]; ok {
+					matched[id] += score
+				} else {
+					delete(matched, id)
+				}
+			}
+		}
+		for id, score := range matched {
+			scores[id] += score
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, Result{ID: id, Score: score})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func (idx *Index) scoreCodes(query string, prefix bool, scores map[uint]float64) {
+	code := NormalizeCode(query)
+	if len(code) < 2 {
+		return
+	}
+	if id, ok := idx.codes[code]; ok {
+		scores[id] += exactCodeScore
+	}
+	if !prefix {
+		return
+	}
+	for indexed, id := range idx.codes {
+		if indexed != code && strings.HasPrefix(indexed, code) {
+			scores[id] += prefixCodeScore
+		}
+	}
+}
+
+// scoreWord scores the documents containing the word, a synonym of it, a term it
+// starts (when prefix is set) or a term a few typos away. Each document keeps its
+// best way of matching the word.
+func (idx *Index) scoreWord(word string, prefix bool) map[uint]float64 {
+	scores := make(map[uint]float64)
+	addTerm := func(term string, factor float64) {
+		list := idx.postings[term]
+		if len(list) == 0 {
+			return
+		}
+		// Rare terms say more about a document than common ones
+		idf := 1 + math.Log(float64(len(idx.docTerms))/float64(len(list)))
+		for _, p := range list {
+			if score := p.weight * idf * factor; score > scores[p.id] {
+				scores[p.id] = score
+			}
+		}
+	}
+
+	addTerm(word, 1)
+	for _, synonym := range idx.synonyms.Of(word) {
+		for _, term := range Tokenize(synonym) {
+			addTerm(term, synonymFactor)
+		}
+	}
+
+	maxTypos := allowedTypos(word)
+	if !prefix && maxTypos == 0 {
+		return scores
+	}
+	for term := range idx.postings {
+		if term == word {
+			continue
+		}
+		if prefix && strings.HasPrefix(term, word) {
+			addTerm(term, prefixFactor)
+			continue
+		}
+		if maxTypos > 0 && abs(len(term)-len(word)) <= maxTypos {
+			if distance := editDistance(word, term, maxTypos); distance <= maxTypos {
+				addTerm(term, typoFactor/float64(distance))
+			}
+		}
+	}
+	return scores
+}
+
+// allowedTypos is 0 for short words, 1 from four letters and 2 from eight.
+func allowedTypos(word string) int {
+	switch n := len([]rune(word)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// editDistance is the optimal string alignment distance (insertions, deletions,
+// substitutions and swaps of neighbours). It gives up early and returns max+1 once
+// the distance is known to exceed max.
+func editDistance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,212 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testIndex() *Index {
+	idx := NewIndex(NewSynonyms(DefaultSynonyms))
+	idx.Rebuild(func() ([]Document, error) {
+		return []Document{
+			{ID: 1, Name: "Semen Gresik 40kg", Category: "Bahan Bangunan"},
+			{ID: 2, Name: "Cat Tembok Putih", Category: "Cat"},
+			{ID: 3, Name: "Paku Beton 5cm"},
+			{ID: 4, Name: "Palu Kambing"},
+			{ID: 5, Name: "Cement Holcim"},
+			{ID: 6, Name: "Kran Air", Codes: []string{"KR-0501", "8991234567890"}},
+			{ID: 7, Name: "Lem Kayu"},
+			{ID: 8, Name: "Kuas", Description: "untuk lem dan cat"},
+		}, nil
+	})
+	return idx
+}
+
+func resultIDs(results []Result) []uint {
+	ids := []uint{}
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"semen", "semen", 2, 0},
+		{"semen", "semne", 2, 1}, // neighbours swapped
+		{"semen", "seme", 2, 1},  // deletion
+		{"semen", "semenn", 2, 1},
+		{"semen", "simen", 2, 1},
+		{"gergaji", "gregaij", 2, 2},
+		{"semen", "kabel", 2, 3}, // gives up past max
+		{"", "paku", 4, 4},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.max); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedTypos(t *testing.T) {
+	tests := []struct {
+		word string
+		want int
+	}{
+		{"cat", 0},
+		{"paku", 1},
+		{"gergaji", 1},
+		{"stopkontak", 2},
+		{"ubin", 1},
+	}
+
+	for _, tt := range tests {
+		if got := allowedTypos(tt.word); got != tt.want {
+			t.Errorf("allowedTypos(%q) = %d, want %d", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestSearchTypoTolerance(t *testing.T) {
+	idx := testIndex()
+
+	tests := []struct {
+		name  string
+		query string
+		want  []uint
+	}{
+		{"swapped letters", "semne", []uint{1}},
+		{"missing letter", "gresk", []uint{1}},
+		{"short words need an exact match", "ct", []uint{}},
+		{"exact word before a typo match", "paku", []uint{3, 4}},
+		{"one typo below eight letters", "kmabign", []uint{}},
+		{"two typos from eight letters", "bnagunna", []uint{1}},
+		{"every word must match", "semne putih", []uint{}},
+	}
+
+	for _, tt := range tests {
+		if got := resultIDs(idx.Search(tt.query, 0, false)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Search(%q) = %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchSynonyms(t *testing.T) {
+	idx := testIndex()
+
+	tests := []struct {
+		name  string
+		query string
+		want  []uint
+	}{
+		{"english to indonesian", "hammer", []uint{4}},
+		{"word itself before its synonym", "cement", []uint{5, 1}},
+		{"synonym both ways", "semen", []uint{1, 5}},
+		{"group of three", "glue", []uint{7, 8}},
+		{"no synonym", "holcim", []uint{5}},
+	}
+
+	for _, tt := range tests {
+		if got := resultIDs(idx.Search(tt.query, 0, false)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Search(%q) = %v, want %v", tt.name, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPrefixAndCodes(t *testing.T) {
+	idx := testIndex()
+
+	tests := []struct {
+		name   string
+		query  string
+		prefix bool
+		want   []uint
+	}{
+		{"unfinished word", "sem", true, []uint{1}},
+		{"unfinished word without prefix", "sem", false, []uint{}},
+		{"only the last word is unfinished", "cat tem", true, []uint{2}},
+		{"earlier words must be complete", "ca tembok", true, []uint{}},
+		{"exact word before a longer one", "cat", true, []uint{2, 8}},
+		{"full sku", "kr0501", false, []uint{6}},
+		{"sku written differently", "KR 0501", false, []uint{6}},
+		{"start of a barcode", "89912", true, []uint{6}},
+		{"start of a barcode without prefix", "89912", false, []uint{}},
+		{"name before description", "lem", false, []uint{7, 8}},
+	}
+
+	for _, tt := range tests {
+		if got := resultIDs(idx.Search(tt.query, 0, tt.prefix)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Search(%q, prefix=%v) = %v, want %v", tt.name, tt.query, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	idx := testIndex()
+
+	if got := resultIDs(idx.Search("lem", 1, false)); !reflect.DeepEqual(got, []uint{7}) {
+		t.Errorf("Search(lem, limit 1) = %v, want [7]", got)
+	}
+}
+
+func TestRebuildKeepsChangesMadeWhileLoading(t *testing.T) {
+	idx := testIndex()
+
+	err := idx.Rebuild(func() ([]Document, error) {
+		// Read before these changes were committed and put into the index
+		stale := []Document{
+			{ID: 1, Name: "Semen Gresik 40kg"},
+			{ID: 3, Name: "Paku Beton 5cm"},
+		}
+		idx.Put(Document{ID: 1, Name: "Semen Tiga Roda 50kg"})
+		idx.Remove(3)
+		idx.Put(Document{ID: 9, Name: "Selang Air"})
+		return stale, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []uint
+	}{
+		{"roda", []uint{1}},
+		{"gresik", []uint{}},
+		{"beton", []uint{}},
+		{"selang", []uint{9}},
+	}
+	for _, tt := range tests {
+		if got := resultIDs(idx.Search(tt.query, 0, false)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("after Rebuild: Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// Changes from before the next rebuild started are not applied again
+	idx.Rebuild(func() ([]Document, error) {
+		return []Document{{ID: 3, Name: "Paku Beton 5cm"}}, nil
+	})
+	if got := idx.Len(); got != 1 {
+		t.Errorf("Len after second Rebuild = %d, want 1", got)
+	}
+}
+
+func TestRebuildErrorKeepsContent(t *testing.T) {
+	idx := testIndex()
+
+	err := idx.Rebuild(func() ([]Document, error) {
+		return nil, errors.New("database unavailable")
+	})
+	if err == nil {
+		t.Fatal("Rebuild did not return the load error")
+	}
+	if got := idx.Len(); got != 8 {
+		t.Errorf("Len after failed Rebuild = %d, want 8", got)
+	}
+}
@@ -0,0 +1,91 @@
+package search
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// DefaultSynonyms are Indonesian and English names used interchangeably in the shop.
+var DefaultSynonyms = [][]string{
+	{"semen", "cement"},
+	{"cat", "paint"},
+	{"paku", "nail"},
+	{"baut", "bolt"},
+	{"mur", "nut"},
+	{"sekrup", "screw"},
+	{"pipa", "pipe"},
+	{"kabel", "cable"},
+	{"lem", "glue"},
+	{"kuas", "brush"},
+	{"kunci", "key", "wrench"},
+	{"palu", "hammer"},
+	{"gergaji", "saw"},
+	{"bor", "drill"},
+	{"keran", "kran", "faucet", "tap"},
+	{"lampu", "lamp", "bulb"},
+	{"saklar", "switch"},
+	{"stopkontak", "socket", "outlet"},
+	{"keramik", "tile"},
+	{"kayu", "wood"},
+	{"besi", "iron", "steel"},
+	{"kawat", "wire"},
+	{"selang", "hose"},
+	{"triplek", "tripleks", "plywood"},
+	{"amplas", "sandpaper"},
+}
+
+// Synonyms maps each word to the other words of its group.
+type Synonyms struct {
+	groups map[string][]string
+}
+
+// NewSynonyms builds the lookup from groups of equivalent words.
+func NewSynonyms(groups [][]string) *Synonyms {
+	s := &Synonyms{groups: make(map[string][]string)}
+	for _, group := range groups {
+		s.add(group)
+	}
+	return s
+}
+
+func (s *Synonyms) add(group []string) {
+	words := make([]string, 0, len(group))
+	for _, word := range group {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			words = append(words, word)
+		}
+	}
+	for _, word := range words {
+		for _, other := range words {
+			if other != word {
+				s.groups[word] = append(s.groups[word], other)
+			}
+		}
+	}
+}
+
+// Of returns the synonyms of a word.
+func (s *Synonyms) Of(word string) []string {
+	return s.groups[strings.ToLower(word)]
+}
+
+// LoadFile adds the groups of a file with one comma separated group per line.
+// Blank lines and lines starting with # are skipped.
+func (s *Synonyms) LoadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s.add(strings.Split(line, ","))
+	}
+	return scanner.Err()
+}
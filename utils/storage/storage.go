@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotFound is returned by Get when the key does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Storage keeps uploaded files under a key such as "items/12/1700000000.jpg".
+type Storage interface {
+	Put(key string, data []byte, contentType string) error
+	Get(key string) (io.ReadCloser, string, error) // body and content type
+	Delete(key string) error
+}
+
+// FromEnv returns the backend selected by STORAGE_DRIVER: "local" (default) or "s3".
+func FromEnv() Storage {
+	if os.Getenv("STORAGE_DRIVER") == "s3" {
+		return NewS3Storage(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") != "false",
+		})
+	}
+
+	dir := os.Getenv("STORAGE_LOCAL_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	return NewLocalStorage(dir)
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+// TerminalIdleTimeout reads TERMINAL_IDLE_MINUTES, default 5. A PIN session left
+// alone this long is locked until its user enters the PIN again.
+func TerminalIdleTimeout() time.Duration {
+	return time.Duration(envInt("TERMINAL_IDLE_MINUTES", 5)) * time.Minute
+}
+
+// TerminalSessionTTL reads TERMINAL_SESSION_HOURS, default 12, the longest a PIN
+// session lasts, refreshes included.
+func TerminalSessionTTL() time.Duration {
+	return time.Duration(envInt("TERMINAL_SESSION_HOURS", 12)) * time.Hour
+}
+
+// ValidatePin accepts 4 to 8 digits that are not all the same digit or a plain
+// ascending or descending run such as 1234.
+func ValidatePin(pin string) error {
+	if len(pin) < 4 || len(pin) > 8 {
+		return errors.New("PIN must be 4 to 8 digits")
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return errors.New("PIN must be 4 to 8 digits")
+		}
+	}
+
+	same, up, down := true, true, true
+	for i := 1; i < len(pin); i++ {
+		step := int(pin[i]) - int(pin[i-1])
+		same = same && step == 0
+		up = up && step == 1
+		down = down && step == -1
+	}
+	if same || up || down {
+		return errors.New("PIN is too easy to guess")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B: 8-digit codes with a 30 second period.
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	keys := map[string][]byte{
+		"SHA1":   []byte("12345678901234567890"),
+		"SHA256": []byte("12345678901234567890123456789012"),
+		"SHA512": []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	algorithms := map[string]func() hash.Hash{
+		"SHA1":   sha1.New,
+		"SHA256": sha256.New,
+		"SHA512": sha512.New,
+	}
+
+	tests := []struct {
+		unix      int64
+		algorithm string
+		want      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, tt := range tests {
+		got := TOTP(keys[tt.algorithm], time.Unix(tt.unix, 0), 30*time.Second, 8, algorithms[tt.algorithm])
+		if got != tt.want {
+			t.Errorf("TOTP(%s, T=%d) = %s, want %s", tt.algorithm, tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyTOTPWindow(t *testing.T) {
+	key := []byte("12345678901234567890")
+	secret := totpEncoding.EncodeToString(key)
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now, TOTPPeriod)
+
+	tests := []struct {
+		name   string
+		offset int64
+		ok     bool
+	}{
+		{"current step", 0, true},
+		{"one step behind", -1, true},
+		{"one step ahead", 1, true},
+		{"two steps behind", -2, false},
+		{"two steps ahead", 2, false},
+	}
+
+	for _, tt := range tests {
+		code := HOTP(key, uint64(int64(current)+tt.offset), TOTPDigits, sha1.New)
+		step, ok := VerifyTOTP(secret, code, now, 0)
+		if ok != tt.ok {
+			t.Errorf("%s: VerifyTOTP ok = %v, want %v", tt.name, ok, tt.ok)
+		}
+		if ok && step != uint64(int64(current)+tt.offset) {
+			t.Errorf("%s: VerifyTOTP step = %d, want %d", tt.name, step, int64(current)+tt.offset)
+		}
+	}
+}
+
+func TestVerifyTOTPRejectsReusedCode(t *testing.T) {
+	key := []byte("12345678901234567890")
+	secret := totpEncoding.EncodeToString(key)
+	now := time.Unix(1111111111, 0)
+	code := HOTP(key, TOTPStep(now, TOTPPeriod), TOTPDigits, sha1.New)
+
+	step, ok := VerifyTOTP(secret, code, now, 0)
+	if !ok {
+		t.Fatal("VerifyTOTP rejected a fresh code")
+	}
+	if _, ok := VerifyTOTP(secret, code, now, step); ok {
+		t.Error("VerifyTOTP accepted a code whose step was already used")
+	}
+	// Still refused a step later, while the code is inside the drift window
+	if _, ok := VerifyTOTP(secret, code, now.Add(TOTPPeriod), step); ok {
+		t.Error("VerifyTOTP accepted a reused code from the previous step")
+	}
+}